@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/qoops-1/vuje/query"
+)
+
+// annotations returns what the encoded values at the visible lines of the displayed document
+// decode to, e.g. Unix timestamps as time, by the line they are at
+func (e *Explorer) annotations() map[int]string {
+	if !e.display.Annotations || e.display.Doc == nil {
+		return nil
+	}
+	doc := e.display.Doc.Interface()
+	paths := linePaths(doc)
+	notes := map[int]string{}
+	for i := e.display.DocOffsetY; i < len(paths) && i < e.display.DocOffsetY+e.layout.ContentHeight; i++ {
+		node, _ := query.Eval(doc, paths[i])
+		switch node.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		if note, ok := query.Annotate(node); ok {
+			notes[i] = note
+		}
+	}
+	return notes
+}
+
+// toggleAnnotations shows or hides what the encoded values decode to
+func (e *Explorer) toggleAnnotations() {
+	e.display.Annotations = !e.display.Annotations
+	if e.display.Annotations {
+		e.message = "annotations of timestamps, base64, JWTs and URL queries are shown"
+	} else {
+		e.message = "annotations are hidden"
+	}
+	e.drawContents(true)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorer_annotations(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"at": 1700000000, "n": 2}`), '.', theme)
+	assert.NoError(t, err)
+	scr := newMemScreen(60, 12)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, []string{"{", `  "at": 1700000000,  ← 2023-11-14 22:13:20 UTC`, `  "n": 2`}, scr.Lines()[2:5])
+
+	e.stop = nil
+	scr = newMemScreen(60, 12, script(termbox.KeyF5)...)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, `  "at": 1700000000,`, scr.Lines()[3])
+	assert.Equal(t, "annotations are hidden", e.message)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+// generators emit type definitions for inferred shapes
+var generators = []struct {
+	name     string
+	flag     string
+	generate func(s *Shape, name string) (string, error)
+}{
+	{name: "Go", flag: "go", generate: goTypes},
+	{name: "TypeScript", flag: "ts", generate: tsTypes},
+	{name: "JSON Schema", flag: "schema", generate: jsonSchema},
+}
+
+// generateCode infers the shape of the node and emits the types in the language named by the -gen option
+func generateCode(node interface{}, name, lang string) (string, error) {
+	var names []string
+	for _, g := range generators {
+		if g.flag == lang {
+			return g.generate(inferShape(node), name)
+		}
+		names = append(names, g.flag)
+	}
+	return "", usageErrorf("unknown language %q, available languages: %s", lang, strings.Join(names, ", "))
+}
+
+// typeName names the type of the node at the path after its last key
+func typeName(path []query.Token) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if k, ok := path[i].(query.Key); ok && k != "" {
+			return goName(string(k))
+		}
+	}
+	return "Root"
+}
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "https": true, "id": true, "ip": true,
+	"json": true, "sql": true, "ttl": true, "uri": true, "url": true, "uuid": true,
+}
+
+// goName converts a JSON key into an exported Go identifier, e.g. "user_id" becomes UserID
+func goName(key string) string {
+	parts := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name := ""
+	for _, p := range parts {
+		if initialisms[strings.ToLower(p)] {
+			name += strings.ToUpper(p)
+			continue
+		}
+		r := []rune(p)
+		name += string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	if name == "" {
+		return "Field"
+	}
+	if !unicode.IsLetter([]rune(name)[0]) {
+		return "F" + name
+	}
+	return name
+}
+
+// singular makes a name of an array element type from the name of the array
+func singular(name string) string {
+	if len(name) > 3 && strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+// typeDefs collects the named types in the order they are first used
+type typeDefs struct {
+	defs []string
+	used map[string]bool
+}
+
+// reserve returns a type name not used yet and the index of its definition
+func (d *typeDefs) reserve(name string) (string, int) {
+	if d.used == nil {
+		d.used = map[string]bool{}
+	}
+	unique := name
+	for i := 2; d.used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	d.used[unique] = true
+	d.defs = append(d.defs, "")
+	return unique, len(d.defs) - 1
+}
+
+// goTypes emits Go struct definitions with json tags. Nullable and optional
+// values are pointers, optional fields are omitted when empty
+func goTypes(s *Shape, name string) (string, error) {
+	d := &typeDefs{}
+	if s.NonNull() != KindObject {
+		name, i := d.reserve(name)
+		d.defs[i] = fmt.Sprintf("type %s %s", name, goType(d, s, name))
+	} else {
+		goType(d, s, name)
+	}
+	src, err := format.Source([]byte(strings.Join(d.defs, "\n\n") + "\n"))
+	return string(src), err
+}
+
+func goType(d *typeDefs, s *Shape, name string) string {
+	var t string
+	switch s.NonNull().numeric() {
+	case KindBool:
+		t = "bool"
+	case KindInt:
+		t = "int64"
+	case KindFloat:
+		t = "float64"
+	case KindString:
+		t = "string"
+	case KindArray:
+		elem := "interface{}"
+		if s.Elem != nil {
+			elem = goType(d, s.Elem, singular(name))
+		}
+		return "[]" + elem
+	case KindObject:
+		t = goStruct(d, s, name)
+	default:
+		return "interface{}"
+	}
+	if s.Nullable() {
+		return "*" + t
+	}
+	return t
+}
+
+func goStruct(d *typeDefs, s *Shape, name string) string {
+	name, i := d.reserve(name)
+	lines := []string{fmt.Sprintf("type %s struct {", name)}
+	fieldNames := map[string]bool{}
+	for _, f := range s.Fields {
+		fieldName := goName(f.Name)
+		for n := 2; fieldNames[fieldName]; n++ {
+			fieldName = fmt.Sprintf("%s%d", goName(f.Name), n)
+		}
+		fieldNames[fieldName] = true
+		t := goType(d, f.Shape, name+goName(f.Name))
+		tag := f.Name
+		if f.Optional(s) {
+			if !strings.HasPrefix(t, "*") && !strings.HasPrefix(t, "[]") && t != "interface{}" {
+				t = "*" + t
+			}
+			tag += ",omitempty"
+		}
+		line := fmt.Sprintf("\t%s %s `json:%q`", fieldName, t, tag)
+		if strings.ContainsAny(f.Name, "\"\\,`'") {
+			line = fmt.Sprintf("\t%s %s `json:\"-\"` // key %q cannot be used in a json tag", fieldName, t, f.Name)
+		}
+		lines = append(lines, line)
+	}
+	d.defs[i] = strings.Join(append(lines, "}"), "\n")
+	return name
+}
+
+// tsTypes emits TypeScript interfaces. Optional fields are marked with "?"
+func tsTypes(s *Shape, name string) (string, error) {
+	d := &typeDefs{}
+	if s.NonNull() != KindObject {
+		name, i := d.reserve(name)
+		d.defs[i] = fmt.Sprintf("export type %s = %s;", name, tsType(d, s, name))
+	} else {
+		tsType(d, s, name)
+	}
+	return strings.Join(d.defs, "\n\n") + "\n", nil
+}
+
+func tsType(d *typeDefs, s *Shape, name string) string {
+	var union []string
+	for _, kind := range []Kind{KindObject, KindArray, KindString, KindInt | KindFloat, KindBool, KindNull} {
+		if s.Kinds&kind == 0 {
+			continue
+		}
+		switch kind {
+		case KindObject:
+			union = append(union, tsInterface(d, s, name))
+		case KindArray:
+			elem := "unknown"
+			if s.Elem != nil {
+				elem = tsType(d, s.Elem, singular(name))
+			}
+			if strings.Contains(elem, " | ") {
+				elem = "(" + elem + ")"
+			}
+			union = append(union, elem+"[]")
+		case KindString:
+			union = append(union, "string")
+		case KindInt | KindFloat:
+			union = append(union, "number")
+		case KindBool:
+			union = append(union, "boolean")
+		case KindNull:
+			union = append(union, "null")
+		}
+	}
+	if len(union) == 0 {
+		return "unknown"
+	}
+	return strings.Join(union, " | ")
+}
+
+func tsInterface(d *typeDefs, s *Shape, name string) string {
+	name, i := d.reserve(name)
+	lines := []string{fmt.Sprintf("export interface %s {", name)}
+	for _, f := range s.Fields {
+		prop := f.Name
+		if !tsIdent.MatchString(prop) {
+			prop = jsonQuote(prop)
+		}
+		if f.Optional(s) {
+			prop += "?"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s;", prop, tsType(d, f.Shape, name+goName(f.Name))))
+	}
+	d.defs[i] = strings.Join(append(lines, "}"), "\n")
+	return name
+}
+
+var tsIdent = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func jsonQuote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// jsonSchema emits a JSON Schema (draft 2020-12) document describing the shape
+func jsonSchema(s *Shape, name string) (string, error) {
+	schema := schemaOf(s)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = name
+	out, err := json.MarshalIndent(schema, "", "  ")
+	return string(out) + "\n", err
+}
+
+func schemaOf(s *Shape) map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch types := s.Kinds.numeric().Names(); len(types) {
+	case 0:
+	case 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+	if s.Kinds&KindObject != 0 {
+		props := map[string]interface{}{}
+		required := []string{}
+		for _, f := range s.Fields {
+			props[f.Name] = schemaOf(f.Shape)
+			if !f.Optional(s) {
+				required = append(required, f.Name)
+			}
+		}
+		schema["properties"] = props
+		schema["required"] = required
+	}
+	if s.Kinds&KindArray != 0 && s.Elem != nil {
+		schema["items"] = schemaOf(s.Elem)
+	}
+	return schema
+}
+
+// typesLines shows the types of the node in all the languages
+func typesLines(node interface{}, name string) []string {
+	var lines []string
+	for _, g := range generators {
+		code, err := g.generate(inferShape(node), name)
+		if err != nil {
+			code = err.Error()
+		}
+		lines = append(lines, strings.ToUpper(g.name), "")
+		for _, l := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+			lines = append(lines, "  "+strings.Replace(l, "\t", "    ", -1))
+		}
+		lines = append(lines, "")
+	}
+	return append(lines, "Press Esc or q to close")
+}
+
+// showTypes shows the types generated from the displayed node
+func (e *Explorer) showTypes() {
+	if e.display.Doc == nil {
+		e.message = "no node to generate types from"
+		return
+	}
+	lines := typesLines(e.display.Doc.Interface(), typeName(e.docPath))
+	e.help = &helpView{name: "types", lines: lines}
+	e.help.view.DocHeight = len(lines)
+	e.fullRedraw()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	assert.NoError(t, d.Decode(&v))
+	return v
+}
+
+const usersDoc = `[
+	{"id": 1, "user_name": "a", "email": null, "score": 1, "addr": {"zip": "1"}},
+	{"id": 2, "user_name": "b", "email": "e", "score": 2.5, "tags": ["x"], "odd-key": true}
+]`
+
+func TestGoTypes(t *testing.T) {
+	code, err := generateCode(decode(t, usersDoc), "Users", "go")
+	assert.NoError(t, err)
+	assert.Equal(t, "type Users []User\n"+
+		"\n"+
+		"type User struct {\n"+
+		"	Addr     *UserAddr `json:\"addr,omitempty\"`\n"+
+		"	Email    *string   `json:\"email\"`\n"+
+		"	ID       int64     `json:\"id\"`\n"+
+		"	OddKey   *bool     `json:\"odd-key,omitempty\"`\n"+
+		"	Score    float64   `json:\"score\"`\n"+
+		"	Tags     []string  `json:\"tags,omitempty\"`\n"+
+		"	UserName string    `json:\"user_name\"`\n"+
+		"}\n"+
+		"\n"+
+		"type UserAddr struct {\n"+
+		"	Zip string `json:\"zip\"`\n"+
+		"}\n", code)
+
+	code, err = generateCode(decode(t, `{"a": [], "b": [1, "x"], "c": null, "d,e": 1}`), "Root", "go")
+	assert.NoError(t, err)
+	assert.Equal(t, "type Root struct {\n"+
+		"	A  []interface{} `json:\"a\"`\n"+
+		"	B  []interface{} `json:\"b\"`\n"+
+		"	C  interface{}   `json:\"c\"`\n"+
+		"	DE int64         `json:\"-\"` // key \"d,e\" cannot be used in a json tag\n"+
+		"}\n", code)
+}
+
+func TestTsTypes(t *testing.T) {
+	code, err := generateCode(decode(t, usersDoc), "Users", "ts")
+	assert.NoError(t, err)
+	assert.Equal(t, `export type Users = User[];
+
+export interface User {
+  addr?: UserAddr;
+  email: string | null;
+  id: number;
+  "odd-key"?: boolean;
+  score: number;
+  tags?: string[];
+  user_name: string;
+}
+
+export interface UserAddr {
+  zip: string;
+}
+`, code)
+
+	code, _ = generateCode(decode(t, `{"a": [1, "x", null], "b": []}`), "Root", "ts")
+	assert.Contains(t, code, "  a: (string | number | null)[];\n  b: unknown[];")
+}
+
+func TestJSONSchema(t *testing.T) {
+	code, err := generateCode(decode(t, usersDoc), "Users", "schema")
+	assert.NoError(t, err)
+	schema := decode(t, code).(map[string]interface{})
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	assert.Equal(t, "array", schema["type"])
+	items := schema["items"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"email", "id", "score", "user_name"}, items["required"])
+	props := items["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"string", "null"}}, props["email"])
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, props["id"])
+	assert.Equal(t, map[string]interface{}{"type": "number"}, props["score"])
+
+	_, err = generateCode(nil, "Root", "rust")
+	assert.EqualError(t, err, `unknown language "rust", available languages: go, ts, schema`)
+}
+
+func TestGoName(t *testing.T) {
+	assert.Equal(t, "UserID", goName("user_id"))
+	assert.Equal(t, "APIURL", goName("api-url"))
+	assert.Equal(t, "F2fa", goName("2fa"))
+	assert.Equal(t, "Field", goName("-"))
+	assert.Equal(t, "Items", typeName([]query.Token{query.Key("items"), query.Index(0)}))
+	assert.Equal(t, "Root", typeName([]query.Token{query.Key("")}))
+}
+
+func TestExplorer_showTypes(t *testing.T) {
+	_, scr, _, _ := runScript(t, `{"user": {"id": 1}}`, "user", termbox.KeyCtrlW)
+	assert.Equal(t, "GO", scr.Line(2))
+	assert.Equal(t, "  type User struct {", scr.Line(4))
+	assert.Equal(t, "      ID int64 `json:\"id\"`", scr.Line(5))
+	assert.Contains(t, scr.Line(11), "types")
+}
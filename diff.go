@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	simplejson "github.com/bitly/go-simplejson"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+// ChangeKind is the kind of a difference between two documents
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+	Moved   ChangeKind = "moved"
+)
+
+// Change is a difference between the old and the new document. Path is the path
+// in the new document, except for removed values that are only in the old one.
+// From is the path of a changed value or a moved element in the old document
+type Change struct {
+	Kind ChangeKind
+	Path []query.Token
+	From []query.Token
+	Old  interface{}
+	New  interface{}
+}
+
+// maxLCS limits the size of arrays compared element by element. Larger arrays
+// that differ in the middle are compared position by position
+const maxLCS = 1 << 20
+
+// diffValues returns the changes that turn the old value into the new one. Elements
+// of arrays are matched by the value of the key if it is given and every element is
+// an object with a unique value of it, otherwise equal elements are matched
+func diffValues(old, new interface{}, key string) []Change {
+	d := &differ{key: key}
+	d.diff(old, new, []query.Token{}, []query.Token{})
+	return d.changes
+}
+
+type differ struct {
+	key     string
+	changes []Change
+}
+
+func (d *differ) add(c Change) {
+	d.changes = append(d.changes, c)
+}
+
+// diff compares the values at oldPath in the old document and at newPath in the new one
+func (d *differ) diff(old, new interface{}, oldPath, newPath []query.Token) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			d.objects(o, n, oldPath, newPath)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			d.arrays(o, n, oldPath, newPath)
+			return
+		}
+	}
+	if !query.Equal(old, new) || query.TypeName(old) != query.TypeName(new) {
+		d.add(Change{Kind: Changed, Path: newPath, From: oldPath, Old: old, New: new})
+	}
+}
+
+func (d *differ) objects(old, new map[string]interface{}, oldPath, newPath []query.Token) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ov, inOld := old[k]
+		nv, inNew := new[k]
+		switch {
+		case !inNew:
+			d.add(Change{Kind: Removed, Path: appendToken(oldPath, query.Key(k)), Old: ov})
+		case !inOld:
+			d.add(Change{Kind: Added, Path: appendToken(newPath, query.Key(k)), New: nv})
+		default:
+			d.diff(ov, nv, appendToken(oldPath, query.Key(k)), appendToken(newPath, query.Key(k)))
+		}
+	}
+}
+
+// pair is an element of the old array matched with an element of the new one
+type pair struct{ old, new int }
+
+func (d *differ) arrays(old, new []interface{}, oldPath, newPath []query.Token) {
+	m := d.match(old, new)
+	for _, i := range m.removed {
+		d.add(Change{Kind: Removed, Path: appendToken(oldPath, query.Index(i)), Old: old[i]})
+	}
+	for _, p := range m.pairs {
+		op, np := appendToken(oldPath, query.Index(p.old)), appendToken(newPath, query.Index(p.new))
+		if m.moved[p] {
+			d.add(Change{Kind: Moved, Path: np, From: op, Old: old[p.old], New: new[p.new]})
+		}
+		d.diff(old[p.old], new[p.new], op, np)
+	}
+	for _, j := range m.added {
+		d.add(Change{Kind: Added, Path: appendToken(newPath, query.Index(j)), New: new[j]})
+	}
+}
+
+// matching tells which elements of the new array are the elements of the old one
+type matching struct {
+	// pairs are sorted by the index in the new array
+	pairs []pair
+	// moved are the pairs out of the order of the others
+	moved map[pair]bool
+	// removed are the indices of the unmatched old elements, added of the new ones
+	removed, added []int
+}
+
+func (d *differ) match(old, new []interface{}) matching {
+	var anchors, moves []pair
+	oldKeys, newKeys, keyed := d.elementKeys(old, new)
+	if keyed {
+		all := matchByKey(oldKeys, newKeys)
+		anchors = increasing(all)
+		moves = without(all, anchors)
+	} else {
+		anchors = matchEqual(old, new)
+	}
+	movedOld, movedNew := map[int]bool{}, map[int]bool{}
+	for _, p := range moves {
+		movedOld[p.old], movedNew[p.new] = true, true
+	}
+	m := matching{pairs: append([]pair{}, anchors...), moved: map[pair]bool{}}
+	next := pair{}
+	for _, a := range append(anchors, pair{len(old), len(new)}) {
+		var gapOld, gapNew []int
+		for ; next.old < a.old; next.old++ {
+			if !movedOld[next.old] {
+				gapOld = append(gapOld, next.old)
+			}
+		}
+		for ; next.new < a.new; next.new++ {
+			if !movedNew[next.new] {
+				gapNew = append(gapNew, next.new)
+			}
+		}
+		// without keys the elements left between matched ones are compared position by position
+		for !keyed && len(gapOld) > 0 && len(gapNew) > 0 {
+			m.pairs = append(m.pairs, pair{gapOld[0], gapNew[0]})
+			gapOld, gapNew = gapOld[1:], gapNew[1:]
+		}
+		m.removed = append(m.removed, gapOld...)
+		m.added = append(m.added, gapNew...)
+		next = pair{a.old + 1, a.new + 1}
+	}
+	// an element removed in one place and added in another one is moved
+	for k := 0; k < len(m.removed); k++ {
+		for n, j := range m.added {
+			if query.Equal(old[m.removed[k]], new[j]) {
+				moves = append(moves, pair{m.removed[k], j})
+				m.removed = append(m.removed[:k], m.removed[k+1:]...)
+				m.added = append(m.added[:n], m.added[n+1:]...)
+				k--
+				break
+			}
+		}
+	}
+	for _, p := range moves {
+		m.moved[p] = true
+	}
+	m.pairs = append(m.pairs, moves...)
+	sort.Slice(m.pairs, func(i, j int) bool { return m.pairs[i].new < m.pairs[j].new })
+	return m
+}
+
+// elementKeys returns the values of the key of the elements when all the elements
+// of both arrays are objects with unique values of the key
+func (d *differ) elementKeys(old, new []interface{}) ([]string, []string, bool) {
+	if d.key == "" {
+		return nil, nil, false
+	}
+	keysOf := func(arr []interface{}) ([]string, bool) {
+		keys := make([]string, len(arr))
+		seen := map[string]bool{}
+		for i, el := range arr {
+			obj, ok := el.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			v, ok := obj[d.key]
+			if !ok {
+				return nil, false
+			}
+			keys[i] = compactJSON(v)
+			if seen[keys[i]] {
+				return nil, false
+			}
+			seen[keys[i]] = true
+		}
+		return keys, true
+	}
+	oldKeys, ok := keysOf(old)
+	if !ok {
+		return nil, nil, false
+	}
+	newKeys, ok := keysOf(new)
+	return oldKeys, newKeys, ok
+}
+
+// matchByKey pairs the elements with equal keys in the order of the new array
+func matchByKey(oldKeys, newKeys []string) []pair {
+	index := map[string]int{}
+	for i, k := range oldKeys {
+		index[k] = i
+	}
+	var pairs []pair
+	for j, k := range newKeys {
+		if i, ok := index[k]; ok {
+			pairs = append(pairs, pair{i, j})
+		}
+	}
+	return pairs
+}
+
+// increasing keeps the longest sequence of pairs in the order of both arrays,
+// elements of the other pairs are moved
+func increasing(pairs []pair) []pair {
+	if len(pairs) == 0 {
+		return nil
+	}
+	// tails[k] is the index of the pair ending the best sequence of length k+1
+	var tails []int
+	prev := make([]int, len(pairs))
+	for i, p := range pairs {
+		k := sort.Search(len(tails), func(k int) bool { return pairs[tails[k]].old >= p.old })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	seq := make([]pair, len(tails))
+	for i, k := tails[len(tails)-1], len(tails)-1; k >= 0; i, k = prev[i], k-1 {
+		seq[k] = pairs[i]
+	}
+	return seq
+}
+
+// matchEqual pairs equal elements keeping their order, which is the longest common
+// subsequence of the arrays. Common prefixes and suffixes are matched first
+func matchEqual(old, new []interface{}) []pair {
+	var pairs []pair
+	pre := 0
+	for pre < len(old) && pre < len(new) && query.Equal(old[pre], new[pre]) {
+		pairs = append(pairs, pair{pre, pre})
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(new)-pre && query.Equal(old[len(old)-1-suf], new[len(new)-1-suf]) {
+		suf++
+	}
+	a, b := old[pre:len(old)-suf], new[pre:len(new)-suf]
+	if len(a)*len(b) <= maxLCS {
+		for _, p := range lcs(a, b) {
+			pairs = append(pairs, pair{pre + p.old, pre + p.new})
+		}
+	}
+	for i := suf; i > 0; i-- {
+		pairs = append(pairs, pair{len(old) - i, len(new) - i})
+	}
+	return pairs
+}
+
+func lcs(a, b []interface{}) []pair {
+	// lengths[i][j] is the length of the LCS of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if query.Equal(a[i], b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	var pairs []pair
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case query.Equal(a[i], b[j]):
+			pairs = append(pairs, pair{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// without returns the pairs that are not in the subsequence of them
+func without(pairs, sub []pair) []pair {
+	in := map[pair]bool{}
+	for _, p := range sub {
+		in[p] = true
+	}
+	var rest []pair
+	for _, p := range pairs {
+		if !in[p] {
+			rest = append(rest, p)
+		}
+	}
+	return rest
+}
+
+func appendToken(path []query.Token, tok query.Token) []query.Token {
+	return append(append([]query.Token{}, path...), tok)
+}
+
+// compactJSON encodes the value on one line without escaping HTML characters
+func compactJSON(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writePatch prints the changes one per line: "+" for added, "-" for removed,
+// "~" for changed values and ">" for moved elements, paths are JSON Pointers
+func writePatch(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		var line string
+		switch c.Kind {
+		case Added:
+			line = fmt.Sprintf("+ %s: %s", pointerOf(c.Path), compactJSON(c.New))
+		case Removed:
+			line = fmt.Sprintf("- %s: %s", pointerOf(c.Path), compactJSON(c.Old))
+		case Changed:
+			line = fmt.Sprintf("~ %s: %s -> %s", pointerOf(c.Path), compactJSON(c.Old), compactJSON(c.New))
+		case Moved:
+			line = fmt.Sprintf("> %s -> %s", pointerOf(c.From), pointerOf(c.Path))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixChanges makes the paths of the changes of the nodes at the path relative to the documents
+func prefixChanges(path []query.Token, changes []Change) []Change {
+	if len(path) > 0 && path[0] == query.Token(query.Key("")) {
+		path = path[1:]
+	}
+	prefixed := make([]Change, len(changes))
+	for i, c := range changes {
+		c.Path = append(append([]query.Token{}, path...), c.Path...)
+		if c.From != nil {
+			c.From = append(append([]query.Token{}, path...), c.From...)
+		}
+		prefixed[i] = c
+	}
+	return prefixed
+}
+
+// diffSummary counts the changes of every kind
+func diffSummary(changes []Change) string {
+	if len(changes) == 0 {
+		return "no differences"
+	}
+	counts := map[ChangeKind]int{}
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	var parts []string
+	for _, kind := range []ChangeKind{Added, Removed, Changed, Moved} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diffView is the side by side diff of the nodes at the same path in both documents
+type diffView struct {
+	left, right *simplejson.Json
+	changes     []Change
+	// leftMarks and rightMarks are the change markers of the lines of both sides
+	leftMarks, rightMarks []rune
+}
+
+// SetOther switches the explorer into diff mode: the document is compared with
+// the other one and the query selects the nodes of both
+func (e *Explorer) SetOther(other *simplejson.Json, key string) {
+	e.other = other
+	e.diffKey = key
+	e.diffs = nil
+}
+
+// diff returns the diff of the nodes selected by the query. The query may select
+// a node in one document only, otherwise the nodes at the displayed path are compared.
+// The diff is computed once per path
+func (e *Explorer) diff() *diffView {
+	path := e.docPath
+	for _, doc := range []*simplejson.Json{e.doc, e.other} {
+		if _, match := query.Eval(doc.Interface(), e.query.Parsed); match == query.Full {
+			path = e.query.Parsed
+		}
+	}
+	cacheKey := query.Format(path, e.query.Sep)
+	if v, ok := e.diffs[cacheKey]; ok {
+		return v
+	}
+	v := &diffView{}
+	if node, match := query.Eval(e.doc.Interface(), path); match == query.Full {
+		v.left = jsonOf(node)
+	}
+	if node, match := query.Eval(e.other.Interface(), path); match == query.Full {
+		v.right = jsonOf(node)
+	}
+	leftMarks, rightMarks := map[string]rune{}, map[string]rune{}
+	switch {
+	case v.left == nil && v.right == nil:
+	case v.left == nil:
+		v.changes = []Change{{Kind: Added, Path: []query.Token{}, New: v.right.Interface()}}
+	case v.right == nil:
+		v.changes = []Change{{Kind: Removed, Path: []query.Token{}, Old: v.left.Interface()}}
+	default:
+		v.changes = diffValues(v.left.Interface(), v.right.Interface(), e.diffKey)
+	}
+	for _, c := range v.changes {
+		switch c.Kind {
+		case Added:
+			rightMarks[pointerKey(c.Path)] = '+'
+		case Removed:
+			leftMarks[pointerKey(c.Path)] = '-'
+		case Changed:
+			leftMarks[pointerKey(c.From)] = '~'
+			rightMarks[pointerKey(c.Path)] = '~'
+		case Moved:
+			leftMarks[pointerKey(c.From)] = '>'
+			rightMarks[pointerKey(c.Path)] = '>'
+		}
+	}
+	if v.left != nil {
+		v.leftMarks = lineMarks(v.left.Interface(), leftMarks)
+	}
+	if v.right != nil {
+		v.rightMarks = lineMarks(v.right.Interface(), rightMarks)
+	}
+	// changed secrets are marked, but their values are masked
+	for _, side := range []**simplejson.Json{&v.left, &v.right} {
+		if *side != nil {
+			*side = jsonOf(e.masked((*side).Interface(), path))
+		}
+	}
+	if e.diffs == nil {
+		e.diffs = map[string]*diffView{}
+	}
+	e.diffs[cacheKey] = v
+	return v
+}
+
+func pointerKey(path []query.Token) string {
+	ptr, _ := query.ToPointer(path)
+	return ptr
+}
+
+// lineMarks returns the marker of every line of the pretty printed value:
+// the one of the closest changed node the line belongs to
+func lineMarks(v interface{}, marks map[string]rune) []rune {
+	paths := linePaths(v)
+	lines := make([]rune, len(paths))
+	for i, path := range paths {
+		for n := len(path); n >= 0; n-- {
+			if m, ok := marks[pointerKey(path[:n])]; ok {
+				lines[i] = m
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// displayDiff draws the nodes of both documents side by side with the changed lines marked
+func (e *Explorer) displayDiff() {
+	v := e.diff()
+	leftWidth := (e.layout.Width - 1) / 2
+	sides := []struct {
+		doc   *simplejson.Json
+		marks []rune
+		x     int
+		width int
+		lines [][]termbox.Cell
+	}{
+		{doc: v.left, marks: v.leftMarks, x: 0, width: leftWidth},
+		{doc: v.right, marks: v.rightMarks, x: leftWidth + 1, width: e.layout.Width - leftWidth - 1},
+	}
+	e.display.DocHeight = 1
+	for i := range sides {
+		if sides[i].doc == nil {
+			continue
+		}
+		json, err := sides[i].doc.EncodePretty()
+		if err != nil {
+			continue
+		}
+		sides[i].lines = *colorizeJSON(json, e.theme)
+		if len(sides[i].lines) > e.display.DocHeight {
+			e.display.DocHeight = len(sides[i].lines)
+		}
+	}
+	e.display.Clamp(e.layout.ContentHeight)
+	for y := 0; y < e.layout.ContentHeight; y++ {
+		n := e.display.DocOffsetY + y
+		e.screen.SetCell(leftWidth, e.layout.ContentY+y, '│', termbox.ColorDefault, termbox.ColorDefault)
+		for i, side := range sides {
+			var cells []termbox.Cell
+			switch {
+			case side.doc == nil && n == 0:
+				cells = cellsOf("--- no node ---", e.theme.Attr(e.theme.Error))
+			case n < len(side.lines):
+				mark := side.marks[n]
+				cells = append(cellsOf(string(markOrSpace(mark))+" ", termbox.ColorDefault), side.lines[n]...)
+				if fg := e.markColor(mark, i == 0); fg != termbox.ColorDefault {
+					for k := range cells {
+						cells[k].Fg = fg
+					}
+				}
+			}
+			e.drawCells(side.x, e.layout.ContentY+y, cells, side.width)
+		}
+	}
+}
+
+func markOrSpace(mark rune) rune {
+	if mark == 0 {
+		return ' '
+	}
+	return mark
+}
+
+// markColor returns the color of the changed lines on the left (old) or the right (new) side
+func (e *Explorer) markColor(mark rune, old bool) termbox.Attribute {
+	switch {
+	case mark == '>':
+		return e.theme.Attr(e.theme.Query)
+	case mark != 0 && old:
+		return e.theme.Attr(e.theme.Removed)
+	case mark != 0:
+		return e.theme.Attr(e.theme.Added)
+	}
+	return termbox.ColorDefault
+}
+
+func cellsOf(s string, fg termbox.Attribute) []termbox.Cell {
+	var cells []termbox.Cell
+	for _, ch := range s {
+		cells = append(cells, termbox.Cell{Ch: ch, Fg: fg})
+	}
+	return cells
+}
+
+// drawCells draws the cells starting at x cutting them at the width
+func (e *Explorer) drawCells(x, y int, cells []termbox.Cell, width int) {
+	for i, c := range cells {
+		if i >= width {
+			break
+		}
+		e.screen.SetCell(x+i, y, c.Ch, c.Fg, c.Bg)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/stretchr/testify/assert"
+)
+
+func patchOf(t *testing.T, old, new, key string) []string {
+	var buf bytes.Buffer
+	assert.NoError(t, writePatch(&buf, diffValues(decode(t, old), decode(t, new), key)))
+	if buf.Len() == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestDiffValues(t *testing.T) {
+	tbl := []struct {
+		old, new, key string
+		res           []string
+	}{
+		{old: `{"a": 1, "b": [1, 2]}`, new: `{"b": [1, 2], "a": 1.0}`},
+		{
+			old: `{"a": 1, "b": "x", "c": {"d": null}}`,
+			new: `{"a": "1", "c": {"d": null, "e": true}, "f": []}`,
+			res: []string{
+				`~ /a: 1 -> "1"`,
+				`- /b: "x"`,
+				"+ /c/e: true",
+				"+ /f: []",
+			},
+		},
+		{
+			old: `[1, 2, 3, 4]`,
+			new: `[1, 5, 3, 4, 6]`,
+			res: []string{"~ /1: 2 -> 5", "+ /4: 6"},
+		},
+		{
+			old: `["a", "b", "c", "d"]`,
+			new: `["d", "a", "b", "c"]`,
+			res: []string{"> /3 -> /0"},
+		},
+		{
+			old: `[{"id": 1, "n": "a"}, {"id": 2, "n": "b"}, {"id": 3, "n": "c"}]`,
+			new: `[{"id": 3, "n": "C"}, {"id": 1, "n": "a"}, {"id": 4, "n": "d"}]`,
+			key: "id",
+			res: []string{
+				`- /1: {"id":2,"n":"b"}`,
+				"> /2 -> /0",
+				`~ /0/n: "c" -> "C"`,
+				`+ /2: {"id":4,"n":"d"}`,
+			},
+		},
+		{
+			// without the key equal elements are matched, the rest are compared by position
+			old: `[{"id": 1, "n": "a"}, {"id": 2, "n": "b"}, {"id": 3}]`,
+			new: `[{"id": 2, "n": "b"}, {"id": 1, "n": "x"}, {"id": 3}]`,
+			res: []string{`- /0: {"id":1,"n":"a"}`, `+ /1: {"id":1,"n":"x"}`},
+		},
+		{
+			old: `[1, {"a": 1}, 3]`,
+			new: `[1, {"a": 2}, 3]`,
+			res: []string{"~ /1/a: 1 -> 2"},
+		},
+		{
+			// the key is ignored when an element does not have it
+			old: `[{"id": 1}, 2]`,
+			new: `[2, {"id": 1}]`,
+			key: "id",
+			res: []string{"> /0 -> /1"},
+		},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.res, patchOf(t, tt.old, tt.new, tt.key), tt.old+" "+tt.new)
+	}
+}
+
+func TestExplorer_diff(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"a": [1, 2], "b": "x"}`), '.', theme)
+	assert.NoError(t, err)
+	other, err := simplejson.NewJson([]byte(`{"a": [2, 1], "b": "y", "c": 3}`))
+	assert.NoError(t, err)
+	e.SetOther(other, "")
+	scr := newMemScreen(40, 12)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, []string{
+		"  {                │  {",
+		`    "a": [         │    "a": [`,
+		">      1,          │       2,",
+		"       2           │>      1",
+		"    ],             │    ],",
+		`~   "b": "x"       │~   "b": "y",`,
+		`  }                │+   "c": 3`,
+		"                   │  }",
+	}, scr.Lines()[2:10])
+	assert.Equal(t, "1 added, 1 changed, 1 moved", diffSummary(e.diff().changes))
+	assert.Contains(t, scr.Line(11), "diff")
+
+	// the query selects the nodes of both documents
+	scr = newMemScreen(40, 12, script("c")...)
+	e.screen = scr
+	e.stop = nil
+	e.Run()
+	assert.Equal(t, "--- no node ---    │+  3", scr.Line(2))
+	assert.Equal(t, "1 added", diffSummary(e.diff().changes))
+}
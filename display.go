@@ -11,25 +11,36 @@ type Display struct {
 	DocOffsetY       int
 	ActiveCompletion int
 	OnlyKeys         bool
+	// Shape shows the shape summary of the document instead of its contents
+	Shape bool
+	// Annotations shows what encoded values, such as timestamps and base64 strings, decode to
+	Annotations bool
 }
 
 // MoveWindow moves DocOffsetY of the display by up to "y" lines, so that
-// the window remains within the boundaries of the document (+1 trailing line)
-func (dspl *Display) MoveWindow(y int, windowSize int) bool {
-	contentsHeight := windowSize - (completionY + 1)
-	moveUpWhenTop := y < 0 && dspl.DocOffsetY == 0
-	moveDownWhenBottom := y > 0 && (dspl.DocOffsetY+contentsHeight == dspl.DocHeight+1)
-	if moveUpWhenTop || moveDownWhenBottom || y == 0 {
+// the window of "contentsHeight" lines remains within the boundaries of the document (+1 trailing line)
+func (dspl *Display) MoveWindow(y int, contentsHeight int) bool {
+	if y == 0 {
 		return false
 	}
-	newOffset := dspl.DocOffsetY + y
+	oldOffset := dspl.DocOffsetY
+	dspl.DocOffsetY += y
+	dspl.Clamp(contentsHeight)
+	return dspl.DocOffsetY != oldOffset
+}
+
+// Clamp keeps the line at the top of the window unchanged unless the window
+// would go past the end of the document (+1 trailing line). Windows of one line
+// or none, on tiny terminals, stop at the last line of the document
+func (dspl *Display) Clamp(contentsHeight int) {
 	maxOffset := dspl.DocHeight - contentsHeight + 1
-	if newOffset <= 0 {
-		dspl.DocOffsetY = 0
-	} else if newOffset >= maxOffset {
+	if maxOffset > dspl.DocHeight-1 {
+		maxOffset = dspl.DocHeight - 1
+	}
+	if dspl.DocOffsetY > maxOffset {
 		dspl.DocOffsetY = maxOffset
-	} else {
-		dspl.DocOffsetY = newOffset
 	}
-	return true
+	if dspl.DocOffsetY < 0 {
+		dspl.DocOffsetY = 0
+	}
 }
@@ -7,115 +7,166 @@ import (
 	"unicode/utf8"
 
 	"github.com/bitly/go-simplejson"
+	"github.com/qoops-1/vuje/query"
 
 	runewidth "github.com/mattn/go-runewidth"
 	termbox "github.com/nsf/termbox-go"
 )
 
 const (
-	prompt      = ">>> "
-	promptY     = 0
-	completionY = promptY + 1
+	prompt  = ">>> "
+	promptY = 0
 )
 
-func drawString(offsetY int, contents string, fgColor termbox.Attribute, bgColor termbox.Attribute) {
+func (e *Explorer) drawString(offsetY int, contents string, fgColor termbox.Attribute, bgColor termbox.Attribute) {
 	var cells []termbox.Cell
 	for _, ch := range contents {
 		cells = append(cells, termbox.Cell{Ch: ch, Fg: fgColor, Bg: bgColor})
 	}
-	drawLine(offsetY, cells)
+	e.drawLine(offsetY, cells)
 }
 
-func drawLine(offsetY int, cells []termbox.Cell) {
+func (e *Explorer) drawLine(offsetY int, cells []termbox.Cell) {
 	for i, c := range cells {
-		termbox.SetCell(i, offsetY, c.Ch, c.Fg, c.Bg)
+		e.screen.SetCell(i, offsetY, c.Ch, c.Fg, c.Bg)
 	}
 }
 
 func (e *Explorer) drawCompletions() {
-	if e.display.ActiveCompletion == -1 || len(e.completions) < 2 {
-		fg := termbox.ColorDefault
-		bg := termbox.ColorDefault
-		var cells []termbox.Cell
-		w, _ := termbox.Size()
-		for i := 0; i < w; i++ {
-			cells = append(cells, termbox.Cell{Ch: ' ', Fg: fg, Bg: bg})
-		}
-		drawLine(completionY, cells)
+	for y := e.layout.CompletionY; y < e.layout.ContentY; y++ {
+		e.clearLine(y)
+	}
+	if !e.completionsShown() {
 		return
 	}
-	var cells []termbox.Cell
-	for i, key := range e.completions {
-		fg := termbox.ColorDefault
-		bg := termbox.ColorDefault
-		if i == e.display.ActiveCompletion {
-			fg = termbox.ColorDefault | termbox.AttrBold
+	lines := wrapCompletions(e.completions, e.layout.Width)
+	first := visibleLines(lines, e.display.ActiveCompletion, e.layout.CompletionHeight)
+	for n, line := range lines[first:] {
+		if n >= e.layout.CompletionHeight {
+			break
 		}
-		for _, char := range key {
-			cells = append(cells, termbox.Cell{Ch: char, Fg: fg, Bg: bg})
+		var cells []termbox.Cell
+		for _, i := range line {
+			fg := termbox.ColorDefault
+			bg := termbox.ColorDefault
+			if i == e.display.ActiveCompletion {
+				fg = e.theme.Attr(e.theme.Selected)
+			}
+			for _, char := range e.completions[i] {
+				cells = append(cells, termbox.Cell{Ch: char, Fg: fg, Bg: bg})
+			}
+			cells = append(cells, termbox.Cell{Ch: ' ', Fg: termbox.ColorDefault, Bg: termbox.ColorDefault})
 		}
-		cells = append(cells, termbox.Cell{Ch: ' ', Fg: termbox.ColorDefault, Bg: termbox.ColorDefault})
+		e.drawLine(e.layout.CompletionY+n, cells)
 	}
-	drawLine(completionY, cells)
+}
+
+func (e *Explorer) completionsShown() bool {
+	return e.display.ActiveCompletion != -1 && len(e.completions) >= 2
 }
 
 func (e *Explorer) drawQueryLine() {
-	termbox.SetCursor(e.query.QueryPos+runewidth.StringWidth(prompt), promptY)
-	var lastToken Token = Key("")
+	if e.prompt != nil {
+		e.drawPrompt()
+		return
+	}
+	e.screen.SetCursor(e.query.QueryPos+runewidth.StringWidth(prompt), promptY)
+	var lastToken query.Token = query.Key("")
 	if len(e.query.Parsed) != 0 {
 		lastToken = e.query.Parsed[len(e.query.Parsed)-1]
 	}
-	firstCompl := bestCompletion(lastToken, e.completions)
+	firstCompl := query.BestCompletion(lastToken, e.completions)
 	promptLen := utf8.RuneCountInString(prompt)
 	queryLen := utf8.RuneCountInString(e.query.Raw())
 	for x, symbol := range prompt + e.query.Raw() + firstCompl {
 		textColor := termbox.ColorDefault
 		if x >= promptLen && x < promptLen+queryLen {
-			textColor = termbox.ColorBlue
+			textColor = e.theme.Attr(e.theme.Query)
 		} else if x > promptLen+queryLen {
-			textColor = termbox.ColorGreen
+			textColor = e.theme.Attr(e.theme.Hint)
 		}
-		termbox.SetCell(x, promptY, symbol, textColor, termbox.ColorDefault)
+		e.screen.SetCell(x, promptY, symbol, textColor, termbox.ColorDefault)
 	}
 }
 
 func (e *Explorer) drawContents(clear bool) {
 	if clear {
-		_, h := termbox.Size()
-		for y := completionY + 1; y < h; y++ {
-			clearLine(y)
+		for y := e.layout.ContentY; y < e.layout.ContentY+e.layout.ContentHeight; y++ {
+			e.clearLine(y)
 		}
 	}
+	if e.layout.ContentHeight <= 0 {
+		return
+	}
+	if e.help != nil {
+		e.drawHelp()
+		return
+	}
+	if e.other != nil {
+		e.displayDiff()
+		return
+	}
 	if e.display.Doc == nil {
-		for x, ch := range "--- no results ---" {
-			termbox.SetCell(x, completionY+1, ch, termbox.ColorRed, termbox.ColorDefault)
-		}
+		e.drawString(e.layout.ContentY, "--- no results ---", e.theme.Attr(e.theme.Error), termbox.ColorDefault)
+		return
+	}
+	if e.display.Shape {
+		e.displayShape()
 		return
 	}
 	if e.display.OnlyKeys {
-		displayKeys(e.display.Doc)
+		e.displayKeys(e.display.Doc)
 		return
 	}
 	json, err := e.display.Doc.EncodePretty()
 	if err != nil {
-		termboxFatalln(err)
+		e.drawString(e.layout.ContentY, fmt.Sprintf("--- %s ---", err), e.theme.Attr(e.theme.Error), termbox.ColorDefault)
+		return
 	}
 	e.display.DocHeight = bytes.Count(json, []byte("\n")) + 1
-	JSONcells := *colorizeJSON(json)
-	for i, line := range JSONcells[e.display.DocOffsetY:] {
-		drawLine(completionY+1+i, line)
+	e.display.Clamp(e.layout.ContentHeight)
+	JSONcells := *colorizeJSON(json, e.theme)
+	notes := e.violationNotes()
+	decoded := e.decodedLines()
+	annotations := e.annotations()
+	changed := e.highlighted()
+	for i := 0; i < e.layout.ContentHeight && e.display.DocOffsetY+i < len(JSONcells); i++ {
+		line := JSONcells[e.display.DocOffsetY+i]
+		if changed[e.display.DocOffsetY+i] {
+			for x := range line {
+				line[x].Fg |= termbox.AttrReverse
+			}
+		}
+		if decoded[e.display.DocOffsetY+i] {
+			for _, ch := range "  ← decoded from a string" {
+				line = append(line, termbox.Cell{Ch: ch, Fg: e.theme.Attr(e.theme.Hint)})
+			}
+		}
+		if note, ok := annotations[e.display.DocOffsetY+i]; ok {
+			for _, ch := range "  ← " + note {
+				line = append(line, termbox.Cell{Ch: ch, Fg: e.theme.Attr(e.theme.Hint)})
+			}
+		}
+		if note, ok := notes[e.display.DocOffsetY+i]; ok {
+			for _, ch := range "  ← " + note {
+				line = append(line, termbox.Cell{Ch: ch, Fg: e.theme.Attr(e.theme.Error)})
+			}
+		}
+		e.drawLine(e.layout.ContentY+i, line)
 	}
 }
 
-func displayKeys(doc *simplejson.Json) {
+func (e *Explorer) displayKeys(doc *simplejson.Json) {
 	obj, err := doc.Map()
 	if err != nil {
+		e.display.DocHeight = 1
+		e.display.Clamp(e.layout.ContentHeight)
 		idxs, err := doc.Array()
 		if err != nil {
-			drawString(completionY+1, "--- not an object or array ---", termbox.ColorRed, termbox.ColorDefault)
+			e.drawString(e.layout.ContentY, "--- not an object or array ---", e.theme.Attr(e.theme.Error), termbox.ColorDefault)
 			return
 		}
-		drawString(completionY+1, fmt.Sprintf("%d..%d", 0, len(idxs)-1), termbox.ColorDefault, termbox.ColorDefault)
+		e.drawString(e.layout.ContentY, fmt.Sprintf("%d..%d", 0, len(idxs)-1), termbox.ColorDefault, termbox.ColorDefault)
 		return
 	}
 	var keys []string
@@ -123,14 +174,16 @@ func displayKeys(doc *simplejson.Json) {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-	for i, k := range keys {
-		drawString(completionY+1+i, k, termbox.ColorDefault, termbox.ColorDefault)
+	e.display.DocHeight = len(keys)
+	e.display.Clamp(e.layout.ContentHeight)
+	for i := 0; i < e.layout.ContentHeight && e.display.DocOffsetY+i < len(keys); i++ {
+		e.drawString(e.layout.ContentY+i, keys[e.display.DocOffsetY+i], termbox.ColorDefault, termbox.ColorDefault)
 	}
 }
 
-func clearLine(y int) {
-	maxX, _ := termbox.Size()
+func (e *Explorer) clearLine(y int) {
+	maxX, _ := e.screen.Size()
 	for i := 0; i < maxX; i++ {
-		termbox.SetCell(i, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		e.screen.SetCell(i, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
 	}
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+// linePrompt asks for a line of text in place of the query line. done is called
+// with the text when Enter is pressed, Esc cancels the prompt
+type linePrompt struct {
+	label string
+	text  []rune
+	pos   int
+	done  func(e *Explorer, text string)
+}
+
+// edit is a change of the document. undo are the JSON Patch operations that revert
+// it, query is the query from before the change
+type edit struct {
+	desc  string
+	undo  []interface{}
+	query string
+}
+
+func (e *Explorer) ask(label, text string, done func(e *Explorer, text string)) {
+	e.display.ActiveCompletion = -1
+	e.prompt = &linePrompt{label: label, text: []rune(text), pos: utf8.RuneCountInString(text), done: done}
+	e.fullRedraw()
+}
+
+func (e *Explorer) promptInput(ev termbox.Event) {
+	p := e.prompt
+	if ev.Key == termbox.KeySpace {
+		ev.Ch = ' '
+	}
+	switch {
+	case ev.Ch != 0:
+		p.text = append(p.text[:p.pos], append([]rune{ev.Ch}, p.text[p.pos:]...)...)
+		p.pos++
+	case ev.Key == termbox.KeyEnter:
+		e.prompt = nil
+		p.done(e, string(p.text))
+	case ev.Key == termbox.KeyEsc, ev.Key == termbox.KeyCtrlG:
+		e.prompt = nil
+	case ev.Key == termbox.KeyCtrlC:
+		e.prompt = nil
+		e.interrupt()
+	case ev.Key == termbox.KeyBackspace, ev.Key == termbox.KeyBackspace2:
+		if p.pos > 0 {
+			p.text = append(p.text[:p.pos-1], p.text[p.pos:]...)
+			p.pos--
+		}
+	case ev.Key == termbox.KeyDelete:
+		if p.pos < len(p.text) {
+			p.text = append(p.text[:p.pos], p.text[p.pos+1:]...)
+		}
+	case ev.Key == termbox.KeyArrowLeft, ev.Key == termbox.KeyCtrlB:
+		if p.pos > 0 {
+			p.pos--
+		}
+	case ev.Key == termbox.KeyArrowRight, ev.Key == termbox.KeyCtrlF:
+		if p.pos < len(p.text) {
+			p.pos++
+		}
+	case ev.Key == termbox.KeyHome, ev.Key == termbox.KeyCtrlA:
+		p.pos = 0
+	case ev.Key == termbox.KeyEnd, ev.Key == termbox.KeyCtrlE:
+		p.pos = len(p.text)
+	case ev.Key == termbox.KeyCtrlU:
+		p.text = p.text[p.pos:]
+		p.pos = 0
+	case ev.Key == termbox.KeyCtrlK:
+		p.text = p.text[:p.pos]
+	}
+	e.fullRedraw()
+}
+
+// drawPrompt draws the prompt on the query line scrolling the text to keep the cursor visible
+func (e *Explorer) drawPrompt() {
+	line := append([]rune(e.prompt.label), e.prompt.text...)
+	cursor := utf8.RuneCountInString(e.prompt.label) + e.prompt.pos
+	start := 0
+	for runewidth.StringWidth(string(line[start:cursor])) >= e.layout.Width && start < cursor {
+		start++
+	}
+	e.clearLine(promptY)
+	x := 0
+	for i, ch := range line[start:] {
+		fg := termbox.ColorDefault
+		if start+i < utf8.RuneCountInString(e.prompt.label) {
+			fg = e.theme.Attr(e.theme.Field)
+		}
+		e.screen.SetCell(x, promptY, ch, fg, termbox.ColorDefault)
+		x += runewidth.RuneWidth(ch)
+	}
+	e.screen.SetCursor(runewidth.StringWidth(string(line[start:cursor])), promptY)
+}
+
+// editTarget returns the JSON Pointer of the displayed node, ok is false if it cannot be edited
+func (e *Explorer) editTarget() (ptr string, node interface{}, ok bool) {
+	if e.display.Doc == nil {
+		e.message = "nothing to edit, the query has no results"
+		return "", nil, false
+	}
+	if constructed(e.docPath) {
+		e.message = "cannot edit the results of projections and functions"
+		return "", nil, false
+	}
+	ptr, err := query.ToPointer(e.docPath)
+	if err != nil {
+		e.message = err.Error()
+		return "", nil, false
+	}
+	if e.decode && e.decoded().within(ptr) {
+		e.message = "cannot edit values decoded from strings, Ctrl+D shows the strings"
+		return "", nil, false
+	}
+	if e.decode || e.redactor != nil {
+		// the values are edited as they are in the document, with the embedded JSON
+		// in strings and with the secrets
+		node, err := pointerGet(e.doc.Interface(), ptr)
+		if err != nil {
+			e.message = err.Error()
+			return "", nil, false
+		}
+		return ptr, node, true
+	}
+	return ptr, e.display.Doc.Interface(), true
+}
+
+// parseValue decodes the JSON value typed by the user
+func parseValue(text string) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(text)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("not a JSON value, strings must be quoted: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("not a JSON value: unexpected text after %s", encodeValue(v))
+	}
+	return v, nil
+}
+
+func patchOperation(op, from, path string, value interface{}) interface{} {
+	o := map[string]interface{}{"op": op, "path": path}
+	if op == "move" {
+		o["from"] = from
+	}
+	if op == "add" || op == "replace" {
+		o["value"] = value
+	}
+	return o
+}
+
+// applyEdit changes the document with the operations and remembers how to undo the change,
+// the query is then set to the pointer "target"
+func (e *Explorer) applyEdit(desc string, ops, undo []interface{}, target string) {
+	if err := e.patchDoc(ops); err != nil {
+		return
+	}
+	e.edits = append(e.edits, edit{desc: desc, undo: undo, query: e.query.Raw()})
+	e.message = desc
+	e.queryPointer(target)
+}
+
+// patchDoc replaces the document with a patched copy of it. A patch that fails
+// leaves the document and everything computed from it as they are
+func (e *Explorer) patchDoc(ops []interface{}) error {
+	doc, err := applyJSONPatch(e.doc.Interface(), ops)
+	if err != nil {
+		if pe, ok := err.(*PatchOpError); ok {
+			err = fmt.Errorf("%s: %s", pe.Op, pe.Msg)
+		}
+		e.message = err.Error()
+		return err
+	}
+	for _, op := range ops {
+		o := op.(map[string]interface{})
+		if o["op"] == "move" {
+			e.docFormat().renamed(o["from"].(string), o["path"].(string))
+		}
+	}
+	e.setDoc(doc)
+	return nil
+}
+
+// setDoc replaces the document dropping everything computed from the old one
+func (e *Explorer) setDoc(doc interface{}) {
+	e.doc = jsonOf(doc)
+	e.embedded = nil
+	e.shapes = nil
+	e.diffs = nil
+	if e.validator != nil {
+		e.violations = e.validator.Validate(doc)
+		e.violation = -1
+		e.noted = nil
+	}
+}
+
+// queryPointer sets the query to the path the pointer points to
+func (e *Explorer) queryPointer(ptr string) {
+	path, _ := query.FromPointer(ptr, e.doc.Interface())
+	e.display.ActiveCompletion = -1
+	e.query.SetRaw(e.query.Format(path))
+	e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+	e.syncWithQuery()
+}
+
+// dirty reports whether the document has changes that are not saved
+func (e *Explorer) dirty() bool {
+	return len(e.edits) != e.savedEdits
+}
+
+func (e *Explorer) editValue() {
+	ptr, old, ok := e.editTarget()
+	if !ok {
+		return
+	}
+	if e.masksSecrets(old) {
+		e.message = "the value has secrets, F12 reveals them to edit it"
+		return
+	}
+	e.askValue("value: ", compactJSON(old), func(e *Explorer, v interface{}) {
+		if query.Equal(v, old) && query.TypeName(v) == query.TypeName(old) {
+			return
+		}
+		e.applyEdit("changed "+pointerOf(tokensOf(ptr, e.doc.Interface())),
+			[]interface{}{patchOperation("replace", "", ptr, v)},
+			[]interface{}{patchOperation("replace", "", ptr, old)}, ptr)
+	})
+}
+
+// askValue asks for a JSON value, the prompt is shown again until the text is valid JSON
+func (e *Explorer) askValue(label, text string, done func(e *Explorer, v interface{})) {
+	e.ask(label, text, func(e *Explorer, text string) {
+		v, err := parseValue(text)
+		if err != nil {
+			e.askValue(label, text, done)
+			e.message = err.Error()
+			return
+		}
+		done(e, v)
+	})
+}
+
+func (e *Explorer) renameKey() {
+	ptr, old, ok := e.editTarget()
+	if !ok {
+		return
+	}
+	// under another key the value would not be masked anymore
+	if e.masksSecrets(old) {
+		e.message = "the value has secrets, F12 reveals them to rename its key"
+		return
+	}
+	path := tokensOf(ptr, e.doc.Interface())
+	var oldKey query.Key
+	ok = false
+	if len(path) > 0 {
+		oldKey, ok = path[len(path)-1].(query.Key)
+	}
+	if !ok {
+		e.message = "only keys of objects can be renamed"
+		return
+	}
+	parent := path[:len(path)-1]
+	e.ask("rename to: ", string(oldKey), func(e *Explorer, key string) {
+		if key == string(oldKey) {
+			return
+		}
+		obj, _ := query.Get(e.doc.Interface(), parent)
+		if _, exists := obj.(map[string]interface{})[key]; exists {
+			e.message = fmt.Sprintf("key %q already exists", key)
+			return
+		}
+		to, _ := query.ToPointer(appendToken(parent, query.Key(key)))
+		e.applyEdit(fmt.Sprintf("renamed %s to %q", pointerOf(path), key),
+			[]interface{}{patchOperation("move", ptr, to, nil)},
+			[]interface{}{patchOperation("move", to, ptr, nil)}, to)
+	})
+}
+
+func (e *Explorer) deleteNode() {
+	ptr, old, ok := e.editTarget()
+	if !ok {
+		return
+	}
+	if ptr == "" {
+		e.message = "the root cannot be deleted"
+		return
+	}
+	path := tokensOf(ptr, e.doc.Interface())
+	parent, _ := query.ToPointer(path[:len(path)-1])
+	e.applyEdit("deleted "+pointerOf(path),
+		[]interface{}{patchOperation("remove", "", ptr, nil)},
+		[]interface{}{patchOperation("add", "", ptr, old)}, parent)
+}
+
+// insertNode adds a key to the displayed object or an element to the end of the displayed array
+func (e *Explorer) insertNode() {
+	ptr, node, ok := e.editTarget()
+	if !ok {
+		return
+	}
+	path := tokensOf(ptr, e.doc.Interface())
+	insert := func(e *Explorer, tok query.Token, v interface{}) {
+		to, _ := query.ToPointer(appendToken(path, tok))
+		e.applyEdit("added "+pointerOf(appendToken(path, tok)),
+			[]interface{}{patchOperation("add", "", to, v)},
+			[]interface{}{patchOperation("remove", "", to, nil)}, to)
+	}
+	switch t := node.(type) {
+	case map[string]interface{}:
+		e.ask("new key: ", "", func(e *Explorer, key string) {
+			if _, exists := t[key]; exists {
+				e.message = fmt.Sprintf("key %q already exists", key)
+				return
+			}
+			e.askValue(fmt.Sprintf("value of %q: ", key), "", func(e *Explorer, v interface{}) {
+				insert(e, query.Key(key), v)
+			})
+		})
+	case []interface{}:
+		e.askValue("append value: ", "", func(e *Explorer, v interface{}) {
+			insert(e, query.Index(len(t)), v)
+		})
+	default:
+		e.message = "select an object or an array to insert into"
+	}
+}
+
+func (e *Explorer) undo() {
+	if len(e.edits) == 0 {
+		e.message = "nothing to undo"
+		return
+	}
+	last := e.edits[len(e.edits)-1]
+	if err := e.patchDoc(last.undo); err != nil {
+		return
+	}
+	if e.savedEdits == len(e.edits) {
+		// the saved version cannot be reached again
+		e.savedEdits = -1
+	}
+	e.edits = e.edits[:len(e.edits)-1]
+	e.message = "undone: " + last.desc
+	e.display.ActiveCompletion = -1
+	e.query.SetRaw(last.query)
+	e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+	e.syncWithQuery()
+}
+
+// tokensOf parses a pointer of the document, it is used for pointers built from valid paths
+func tokensOf(ptr string, doc interface{}) []query.Token {
+	path, _ := query.FromPointer(ptr, doc)
+	return path
+}
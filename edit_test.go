@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorer_edit(t *testing.T) {
+	doc := `{"name": "x", "on": false, "list": [1]}`
+
+	// the value is prefilled, Ctrl+U clears it
+	e, scr, _, _ := runScript(t, doc, "on", termbox.KeyF2, termbox.KeyCtrlU, "true", termbox.KeyEnter)
+	assert.Equal(t, `{"list":[1],"name":"x","on":true}`, compactJSON(e.doc.Interface()))
+	assert.Equal(t, "changed /on", e.message)
+	assert.Contains(t, scr.Line(11), "json [+]")
+	e, scr, _, _ = runScript(t, doc, "on", termbox.KeyF2, termbox.KeyCtrlU, "true", termbox.KeyEnter, termbox.KeyCtrlZ)
+	assert.Equal(t, false, e.doc.Get("on").MustBool(true))
+	assert.False(t, e.dirty())
+	assert.NotContains(t, scr.Line(11), "[+]")
+
+	e, _, _, _ = runScript(t, doc, "name", termbox.KeyF3, termbox.KeyCtrlU, "title", termbox.KeyEnter)
+	assert.Equal(t, `{"list":[1],"on":false,"title":"x"}`, compactJSON(e.doc.Interface()))
+	assert.Equal(t, "title", e.query.Raw())
+
+	e, _, _, _ = runScript(t, doc, "list", termbox.KeyDelete)
+	assert.Equal(t, `{"name":"x","on":false}`, compactJSON(e.doc.Interface()))
+	assert.Equal(t, "", e.query.Raw())
+
+	e, _, _, _ = runScript(t, doc, "list", termbox.KeyF4, `{"a": null}`, termbox.KeyEnter)
+	assert.Equal(t, `[1,{"a":null}]`, compactJSON(e.doc.Get("list").Interface()))
+	assert.Equal(t, "list[1]", e.query.Raw())
+
+	e, _, _, _ = runScript(t, doc, termbox.KeyInsert, "a.b", termbox.KeyEnter, "2", termbox.KeyEnter)
+	assert.Equal(t, `{"a.b":2,"list":[1],"name":"x","on":false}`, compactJSON(e.doc.Interface()))
+	assert.Equal(t, `["a.b"]`, e.query.Raw())
+	assert.Len(t, e.edits, 1)
+
+	// invalid values are asked for again, Esc cancels
+	e, scr, _, _ = runScript(t, doc, "name", termbox.KeyF2, termbox.KeyCtrlU, "y", termbox.KeyEnter)
+	assert.Equal(t, "value: y", scr.Line(0))
+	assert.Equal(t, "not a JSON value, strings must be quoted: invalid character 'y' looking for beginning of value", e.message)
+	e, scr, _, _ = runScript(t, doc, "name", termbox.KeyF2, termbox.KeyCtrlU, "y", termbox.KeyEnter, termbox.KeyEsc)
+	assert.Equal(t, ">>> name", scr.Line(0))
+	assert.Empty(t, e.edits)
+
+	e, _, _, _ = runScript(t, doc, termbox.KeyDelete)
+	assert.Equal(t, "the root cannot be deleted", e.message)
+	e, _, _, _ = runScript(t, doc, "list|length", termbox.KeyF2)
+	assert.Equal(t, "cannot edit the results of projections and functions", e.message)
+
+	// quitting with unsaved changes asks for confirmation
+	e, _, _, err := runScript(t, doc, "on", termbox.KeyDelete, termbox.KeyCtrlC)
+	assert.Nil(t, e.stop)
+	assert.Equal(t, io.EOF, err)
+	assert.Contains(t, e.message, "unsaved changes")
+	_, _, _, err = runScript(t, doc, "on", termbox.KeyDelete, termbox.KeyCtrlC, termbox.KeyCtrlC)
+	assert.Equal(t, Interrupted, err.(*Error).Kind)
+}
+
+func TestExplorer_failedEdit(t *testing.T) {
+	e, _, _, _ := runScript(t, `{"a": 1, "b": [1]}`, "b")
+	shape := e.shape()
+	ops := []interface{}{
+		patchOperation("replace", "", "/a", 2),
+		patchOperation("add", "", "/b/5", 3),
+	}
+	e.applyEdit("changed /a", ops, nil, "/a")
+	assert.Equal(t, "add /b/5: index 5 is out of range, the array has 1 element", e.message)
+	assert.Equal(t, `{"a":1,"b":[1]}`, compactJSON(e.doc.Interface()))
+	assert.Empty(t, e.edits)
+	assert.Equal(t, "b", e.query.Raw())
+	assert.True(t, shape == e.shape())
+
+	// the undo that fails is kept
+	e.edits = []edit{{desc: "changed /c", undo: []interface{}{patchOperation("remove", "", "/c", nil)}}}
+	e.undo()
+	assert.Equal(t, `remove /c: no key "c"`, e.message)
+	assert.Len(t, e.edits, 1)
+	assert.Equal(t, `{"a":1,"b":[1]}`, compactJSON(e.doc.Interface()))
+}
+
+func TestExplorer_save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vuje")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "config.json")
+	input := "{\n    \"z\": 1,\n    \"a/b\": {\"y\": [], \"x\": \"<&>\"},\n    \"m\": 1.50\n}\n"
+	assert.NoError(t, ioutil.WriteFile(name, []byte(input), 0600))
+
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(input), '.', theme)
+	assert.NoError(t, err)
+	e.SetFile(name)
+	e.screen = newMemScreen(40, 12, script(`["a/b"].y`, termbox.KeyF3, termbox.KeyCtrlU, "w", termbox.KeyEnter,
+		termbox.KeyF4, "true", termbox.KeyEnter, termbox.KeyCtrlS)...)
+	e.Run()
+	assert.Equal(t, "saved 112 B to "+name, e.message)
+	assert.False(t, e.dirty())
+	data, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n    \"z\": 1,\n    \"a/b\": {\n        \"w\": [\n            true\n        ],\n        \"x\": \"<&>\"\n    },\n    \"m\": 1.50\n}\n", string(data))
+	info, err := os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// without a file the name is asked for, undoing past the save makes the document dirty
+	other := filepath.Join(dir, "other.json")
+	e.SetFile("")
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script(termbox.KeyCtrlS, other, termbox.KeyEnter, termbox.KeyCtrlZ)...)
+	e.Run()
+	assert.Equal(t, other, e.file)
+	assert.True(t, e.dirty())
+	data, err = ioutil.ReadFile(other)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"w": [`)
+}
+
+func TestDetectFormat(t *testing.T) {
+	f := detectFormat([]byte(`{"b": {"d": 1, "c": 2}, "a": [{"y": 1, "x": 2}]}`))
+	assert.Equal(t, "", f.indent)
+	assert.False(t, f.newline)
+	data, err := f.encode(decode(t, `{"a": [{"x": 2, "z": 3, "y": 1}], "b": {"c": 2, "d": 1}, "0": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"d":1,"c":2},"a":[{"y":1,"x":2,"z":3}],"0":null}`, string(data))
+
+	f = detectFormat([]byte("[\n\t1\n]"))
+	assert.Equal(t, "\t", f.indent)
+	f.renamed("/0", "/1")
+	data, err = f.encode(decode(t, `[{}, []]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n\t{},\n\t[]\n]", string(data))
+}
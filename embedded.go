@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+// embeddedDoc is a document with the strings holding JSON objects and arrays decoded
+type embeddedDoc struct {
+	doc interface{}
+	// decoded are the JSON Pointers of the decoded strings
+	decoded map[string]bool
+}
+
+// decodeEmbedded replaces the strings that hold JSON objects or arrays with their values.
+// Strings found in the decoded values are decoded as well. The document is not changed,
+// the containers on the way to the decoded strings are copied
+func decodeEmbedded(doc interface{}) *embeddedDoc {
+	d := &embeddedDoc{decoded: map[string]bool{}}
+	d.doc, _ = d.walk(doc, "")
+	return d
+}
+
+// walk returns the value with the embedded JSON decoded and whether anything was decoded
+func (d *embeddedDoc) walk(v interface{}, ptr string) (interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		var obj map[string]interface{}
+		for k, el := range t {
+			dec, changed := d.walk(el, ptr+"/"+pointerEscaper.Replace(k))
+			if !changed {
+				continue
+			}
+			if obj == nil {
+				obj = make(map[string]interface{}, len(t))
+				for k, el := range t {
+					obj[k] = el
+				}
+			}
+			obj[k] = dec
+		}
+		if obj == nil {
+			return v, false
+		}
+		return obj, true
+	case []interface{}:
+		var arr []interface{}
+		for i, el := range t {
+			dec, changed := d.walk(el, ptr+"/"+strconv.Itoa(i))
+			if !changed {
+				continue
+			}
+			if arr == nil {
+				arr = append([]interface{}{}, t...)
+			}
+			arr[i] = dec
+		}
+		if arr == nil {
+			return v, false
+		}
+		return arr, true
+	case string:
+		val, ok := parseEmbedded(t)
+		if !ok {
+			return v, false
+		}
+		d.decoded[ptr] = true
+		val, _ = d.walk(val, ptr)
+		return val, true
+	}
+	return v, false
+}
+
+// parseEmbedded decodes the string if it holds a JSON object or array and nothing else.
+// Other values are kept as strings, so "42" or "true" stay strings
+func parseEmbedded(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(trimmed)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return v, true
+}
+
+// extend decodes the elements appended to the array of the document from the index on
+func (d *embeddedDoc) extend(arr []interface{}, from int) {
+	dec, _ := d.doc.([]interface{})
+	// until a string is decoded, the decoded array is the array of the document
+	shared := len(d.decoded) == 0
+	if shared {
+		dec = arr
+	}
+	for i := from; i < len(arr); i++ {
+		v, changed := d.walk(arr[i], "/"+strconv.Itoa(i))
+		if changed && shared {
+			dec = append([]interface{}{}, arr...)
+			shared = false
+		}
+		switch {
+		case shared:
+		case i < len(dec):
+			dec[i] = v
+		default:
+			dec = append(dec, v)
+		}
+	}
+	d.doc = dec
+}
+
+// within reports whether the pointer points to a decoded string or into one
+func (d *embeddedDoc) within(ptr string) bool {
+	for p := ptr; ; p = p[:strings.LastIndex(p, "/")] {
+		if d.decoded[p] {
+			return true
+		}
+		if p == "" {
+			return false
+		}
+	}
+}
+
+// queried returns the document queries are evaluated against, with the embedded
+// JSON decoded when decoding is on
+func (e *Explorer) queried() interface{} {
+	if !e.decode {
+		return e.doc.Interface()
+	}
+	return e.decoded().doc
+}
+
+// decoded returns the document with the embedded JSON decoded, it is decoded again after changes
+func (e *Explorer) decoded() *embeddedDoc {
+	if e.embedded == nil {
+		e.embedded = decodeEmbedded(e.doc.Interface())
+	}
+	return e.embedded
+}
+
+// toggleDecode switches between stepping into the strings holding JSON and showing them as they are
+func (e *Explorer) toggleDecode() {
+	e.decode = !e.decode
+	e.display.ActiveCompletion = -1
+	e.shapes = nil
+	e.diffs = nil
+	e.syncWithQuery()
+	if !e.decode {
+		e.message = "strings are shown as they are"
+		return
+	}
+	if n := len(e.decoded().decoded); n > 0 {
+		e.message = "decoded " + plural(n, "string") + " holding JSON"
+	} else {
+		e.message = "no strings hold JSON objects or arrays"
+	}
+}
+
+// decodedLines returns the lines of the displayed document that start values decoded from strings
+func (e *Explorer) decodedLines() map[int]bool {
+	if !e.decode || e.display.Doc == nil || constructed(e.docPath) {
+		return nil
+	}
+	decoded := e.decoded().decoded
+	if len(decoded) == 0 {
+		return nil
+	}
+	prefix, err := query.ToPointer(e.docPath)
+	if err != nil {
+		return nil
+	}
+	lines := map[int]bool{}
+	seen := map[string]bool{}
+	for i, path := range linePaths(e.display.Doc.Interface()) {
+		ptr, _ := query.ToPointer(path)
+		// closing brackets point to their container, the mark goes to the opening line only
+		if seen[ptr] {
+			continue
+		}
+		seen[ptr] = true
+		if decoded[prefix+ptr] {
+			lines[i] = true
+		}
+	}
+	return lines
+}
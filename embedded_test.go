@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeEmbedded(t *testing.T) {
+	doc := decode(t, `{"body": "{\"a\": [1, \"[\\\"x\\\"]\"]}", "n": "42", "bad": "{a}", "more": "[1] [2]", "list": [" {}", "x"]}`)
+	d := decodeEmbedded(doc)
+	assert.Equal(t, `{"bad":"{a}","body":{"a":[1,["x"]]},"list":[{},"x"],"more":"[1] [2]","n":"42"}`, compactJSON(d.doc))
+	assert.Equal(t, map[string]bool{"/body": true, "/body/a/1": true, "/list/0": true}, d.decoded)
+	assert.True(t, d.within("/body/a"))
+	assert.False(t, d.within("/list"))
+	// the document stays as it is
+	assert.Equal(t, `{"bad":"{a}","body":"{\"a\": [1, \"[\\\"x\\\"]\"]}","list":[" {}","x"],"more":"[1] [2]","n":"42"}`, compactJSON(doc))
+}
+
+func TestEmbeddedDoc_extend(t *testing.T) {
+	arr := append(make([]interface{}, 0, 8), "a")
+	d := decodeEmbedded(arr)
+	arr = append(arr, "[1]", "b")
+	d.extend(arr, 1)
+	assert.Equal(t, `["a",[1],"b"]`, compactJSON(d.doc))
+	assert.Equal(t, map[string]bool{"/1": true}, d.decoded)
+	arr = append(arr, `{"c": "[2]"}`)
+	d.extend(arr, 3)
+	assert.Equal(t, `["a",[1],"b",{"c":[2]}]`, compactJSON(d.doc))
+	// the array of the document keeps the strings
+	assert.Equal(t, `["a","[1]","b","{\"c\": \"[2]\"}"]`, compactJSON(arr))
+}
+
+func TestExplorer_decode(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"body": "{\"id\": 7}", "n": 1}`), '.', theme)
+	assert.NoError(t, err)
+	scr := newMemScreen(40, 12, script(termbox.KeyCtrlD)...)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, "decoded 1 string holding JSON", e.message)
+	assert.Equal(t, []string{"{", `  "body": {  ← decoded from a string`, `    "id": 7`, "  },"}, scr.Lines()[2:6])
+
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("body.id", termbox.KeyF2)...)
+	e.Run()
+	assert.Equal(t, "7", compactJSON(e.display.Doc.Interface()))
+	assert.Equal(t, "cannot edit values decoded from strings, Ctrl+D shows the strings", e.message)
+
+	// values outside of the strings are edited in the document
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("n", termbox.KeyF2, termbox.KeyCtrlU, "2", termbox.KeyEnter)...)
+	e.Run()
+	assert.Equal(t, `{"body":"{\"id\": 7}","n":2}`, compactJSON(e.doc.Interface()))
+
+	res, err := e.ExecuteQuery("body.id")
+	assert.NoError(t, err)
+	assert.Equal(t, "7", compactJSON(res.Interface()))
+	e.decode = false
+	_, err = e.ExecuteQuery("body.id")
+	assert.Equal(t, NotFoundError, err.(*Error).Kind)
+}
+
+func TestExplorer_decodeShape(t *testing.T) {
+	// the cached shape of the strings is not shown for the decoded document
+	e, scr, _, _ := runScript(t, `{"body": "{\"id\": 7}"}`, termbox.KeyCtrlX, termbox.KeyCtrlD)
+	assert.Equal(t, []string{
+		"PATH     TYPES    SEEN  VALUES",
+		"(root)   object      1",
+		"body     object    1/1",
+		"body.id  integer   1/1  7  [7..7]",
+	}, scr.Lines()[2:6])
+	e.stop = nil
+	scr = newMemScreen(40, 12, script(termbox.KeyCtrlD)...)
+	e.screen = scr
+	e.Run()
+	assert.True(t, e.display.Shape)
+	assert.Equal(t, []string{
+		"PATH    TYPES   SEEN  VALUES",
+		"(root)  object     1",
+		`body    string   1/1  "{\"id\": 7}"`,
+		"",
+	}, scr.Lines()[2:6])
+}
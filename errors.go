@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/qoops-1/vuje/query"
+)
+
+// ErrorKind classifies the errors that terminate the program. Every kind has its own exit code
+type ErrorKind string
+
+const (
+	UsageError    ErrorKind = "usage"
+	InputError    ErrorKind = "input"
+	NotFoundError ErrorKind = "not_found"
+	OutputError   ErrorKind = "output"
+	InvalidError  ErrorKind = "invalid"
+	PatchError    ErrorKind = "patch"
+	Interrupted   ErrorKind = "interrupted"
+)
+
+// Exit codes of the program
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInput       = 3
+	exitNotFound    = 4
+	exitOutput      = 5
+	exitInvalid     = 6
+	exitPatch       = 7
+	exitInterrupted = 130
+)
+
+// ExitCode returns the exit code of the program terminated by an error of this kind
+func (k ErrorKind) ExitCode() int {
+	switch k {
+	case UsageError:
+		return exitUsage
+	case InputError:
+		return exitInput
+	case NotFoundError:
+		return exitNotFound
+	case OutputError:
+		return exitOutput
+	case InvalidError:
+		return exitInvalid
+	case PatchError:
+		return exitPatch
+	case Interrupted:
+		return exitInterrupted
+	}
+	return exitFailure
+}
+
+// Error is an error that terminates the program. Line and Column point to the
+// place in the input or in the query the error was found at, they are 0 if unknown
+type Error struct {
+	Kind   ErrorKind
+	Err    error
+	Line   int
+	Column int
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+	}
+	if e.Column > 0 {
+		return fmt.Sprintf("column %d: %s", e.Column, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+func usageErrorf(format string, args ...interface{}) *Error {
+	return &Error{Kind: UsageError, Err: fmt.Errorf(format, args...)}
+}
+
+// inputError describes a failure to decode the JSON document "data". Position of
+// syntax errors is converted into line and column
+func inputError(data []byte, err error) *Error {
+	offset := int64(-1)
+	switch t := errors.Cause(err).(type) {
+	case *json.SyntaxError:
+		offset = t.Offset
+	case *json.UnmarshalTypeError:
+		offset = t.Offset
+	}
+	e := &Error{Kind: InputError, Err: errors.Wrap(err, "cant parse json")}
+	if offset >= 0 {
+		e.Line, e.Column = position(data, offset)
+	}
+	return e
+}
+
+// position converts the byte offset into line and column, both starting with 1.
+// json.SyntaxError points right after the unexpected byte, so the offset of the
+// error is the one of the last byte read
+func position(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line, column = 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	if column > 1 {
+		column--
+	}
+	return line, column
+}
+
+// classifyQueryError classifies the errors of the query package. The position of
+// syntax errors becomes the column, so that it is given once and counted from 1
+func classifyQueryError(err error) *Error {
+	switch t := err.(type) {
+	case *query.SyntaxError:
+		return &Error{Kind: UsageError, Err: fmt.Errorf("bad query %q: %s", t.Query, t.Msg), Column: t.Pos + 1}
+	case *query.NotFoundError:
+		return &Error{Kind: NotFoundError, Err: t}
+	case *query.FuncError:
+		return &Error{Kind: NotFoundError, Err: t}
+	}
+	return &Error{Kind: UsageError, Err: err}
+}
+
+// reportError prints the error either as text or as a JSON object and returns the exit code
+func reportError(w io.Writer, err error, asJSON bool) int {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Err: err}
+	}
+	if !asJSON {
+		fmt.Fprintf(w, "vuje: %s\n", e)
+		return e.Kind.ExitCode()
+	}
+	report := struct {
+		Error    ErrorKind `json:"error"`
+		Message  string    `json:"message"`
+		Line     int       `json:"line,omitempty"`
+		Column   int       `json:"column,omitempty"`
+		ExitCode int       `json:"exitCode"`
+	}{
+		Error:    e.Kind,
+		Message:  e.Err.Error(),
+		Line:     e.Line,
+		Column:   e.Column,
+		ExitCode: e.Kind.ExitCode(),
+	}
+	if report.Error == "" {
+		report.Error = "failure"
+	}
+	json.NewEncoder(w).Encode(report)
+	return e.Kind.ExitCode()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExplorer_inputError(t *testing.T) {
+	_, err := NewExplorer(strings.NewReader("{\n  \"a\": 1,\n  \"b\" 2\n}"), '.', &Theme{})
+	e, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, InputError, e.Kind)
+	assert.Equal(t, 3, e.Line)
+	assert.Equal(t, 7, e.Column)
+	assert.Equal(t, exitInput, e.Kind.ExitCode())
+}
+
+func TestExecuteQuery_errors(t *testing.T) {
+	e, err := NewExplorer(strings.NewReader(`{"a": [1]}`), '.', &Theme{})
+	assert.NoError(t, err)
+
+	_, err = e.ExecuteQuery("a[1]")
+	assert.Equal(t, NotFoundError, err.(*Error).Kind)
+	_, err = e.ExecuteQuery("a[x]")
+	assert.Equal(t, UsageError, err.(*Error).Kind)
+	assert.Equal(t, 2, err.(*Error).Column)
+	// the position is given once, as the column
+	assert.EqualError(t, err, `column 2: bad query "a[x]": expected index in square brackets`)
+	res, err := e.ExecuteQuery("a[0]")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.MustInt())
+}
+
+func TestReportError(t *testing.T) {
+	var buf bytes.Buffer
+	err := &Error{Kind: InputError, Err: errors.New("invalid character"), Line: 3, Column: 7}
+	assert.Equal(t, exitInput, reportError(&buf, err, false))
+	assert.Equal(t, "vuje: line 3, column 7: invalid character\n", buf.String())
+
+	buf.Reset()
+	assert.Equal(t, exitInput, reportError(&buf, err, true))
+	assert.Equal(t, `{"error":"input","message":"invalid character","line":3,"column":7,"exitCode":3}`+"\n", buf.String())
+
+	buf.Reset()
+	assert.Equal(t, exitFailure, reportError(&buf, errors.New("boom"), true))
+	assert.Equal(t, `{"error":"failure","message":"boom","exitCode":1}`+"\n", buf.String())
+}
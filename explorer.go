@@ -2,10 +2,12 @@ package main
 
 import (
 	"io"
-	"log"
+	"io/ioutil"
+	"time"
 	"unicode/utf8"
 
 	"github.com/bitly/go-simplejson"
+	"github.com/qoops-1/vuje/query"
 
 	termbox "github.com/nsf/termbox-go"
 	"github.com/pkg/errors"
@@ -16,12 +18,73 @@ type Explorer struct {
 	display     *Display
 	query       *Query
 	completions []string
+	theme       *Theme
+	layout      Layout
+	// docPath is the path to the displayed document
+	docPath []query.Token
+	// message is shown in the status bar until the next key press
+	message string
+	keymap  []binding
+	help    *helpView
+	// shapes caches shape summaries by the path of the node
+	shapes map[string]*shapeView
+	// violations of the schema set with SetValidator, violation is the one jumped to last
+	validator  *Validator
+	violations []Violation
+	violation  int
+	// other is the document compared with doc in diff mode, diffKey matches elements of arrays
+	other   *simplejson.Json
+	diffKey string
+	diffs   map[string]*diffView
+	// prompt replaces the query line while a value, a key or a file name is asked for
+	prompt *linePrompt
+	// edits can be undone one by one, savedEdits is the number of them at the last save
+	edits      []edit
+	savedEdits int
+	// file the document is saved to, source is the input until its format is detected
+	file        string
+	source      []byte
+	format      *docFormat
+	confirmQuit bool
+	// reloads receives the new versions of the watched file, the values changed
+	// by the last reload are highlighted until highlightUntil. saved is what was
+	// written to the file last, reloading it would drop the undo history
+	reloads        chan reload
+	saved          []byte
+	highlight      map[string]bool
+	highlightUntil time.Time
+	// decode steps into the strings holding JSON, embedded is the document with them decoded
+	decode   bool
+	embedded *embeddedDoc
+	// redactor masks the secrets in the views unless reveal is set, and always in the output
+	redactor *redactor
+	reveal   bool
+	// follow reads the records of the stream given with Follow
+	follow *follower
+	// result is set when the user chooses the document to print
+	result *simplejson.Json
+	// stop is set when the explorer should exit without a result
+	stop   error
+	screen Screen
+	// sized is the displayed node the serialized size was computed for
+	sized *simplejson.Json
+	size  int
+	// queryErr tells why the query has no results, it is found along with the results
+	queryErr string
+	// notes are the violations by the line of noted, the displayed node they were found for
+	noted *simplejson.Json
+	notes map[int]string
 }
 
-func NewExplorer(document io.Reader, sep rune) *Explorer {
-	jsonDoc, err := simplejson.NewFromReader(document)
+// NewExplorer reads and parses the JSON document. Errors are reported as *Error
+func NewExplorer(document io.Reader, sep rune, theme *Theme) (*Explorer, error) {
+	data, err := ioutil.ReadAll(document)
 	if err != nil {
-		log.Fatalln(errors.Wrap(err, "cant parse json"))
+		return nil, &Error{Kind: InputError, Err: errors.Wrap(err, "cant read input")}
+	}
+	jsonDoc, err := simplejson.NewJson(data)
+	if err != nil {
+		return nil, inputError(data, err)
 	}
 	q := &Query{
 		QueryPos: 0,
@@ -29,109 +92,89 @@ func NewExplorer(document io.Reader, sep rune) *Explorer {
 		raw:      "",
 	}
 	return &Explorer{
-		doc:   jsonDoc,
-		query: q,
+		doc:    jsonDoc,
+		source: data,
+		query:  q,
 		display: &Display{
 			DocOffsetY:       0,
 			ActiveCompletion: -1,
 			OnlyKeys:         false,
+			Annotations:      true,
 		},
 		completions: []string{},
+		theme:       theme,
+		keymap:      defaultKeymap(),
+		screen:      termboxScreen{},
+	}, nil
+}
+
+// ExecuteQuery processes the query in non-interactive mode. The secrets are masked
+// in the result. Errors are reported as *Error
+func (e *Explorer) ExecuteQuery(rawQuery string) (*simplejson.Json, error) {
+	node, toks, err := e.executeQuery(rawQuery)
+	if err != nil {
+		return nil, err
 	}
+	return e.exported(node, toks), nil
 }
 
-// ExecuteQuery processes the query in non-interactive mode
-func (e *Explorer) ExecuteQuery(query string) *simplejson.Json {
-	e.query.SetRaw(query)
-	toks := e.query.Parsed
-	currentDoc, full := traverse(e.doc, toks)
-	if !full {
-		log.Fatalf("Bad query: %s", toks[len(toks)-1])
+// executeQuery returns the node selected by the query with the secrets in it
+func (e *Explorer) executeQuery(rawQuery string) (*simplejson.Json, []query.Token, error) {
+	toks, err := compileQuery(rawQuery, e.query.Sep, e.queried())
+	if err != nil {
+		return nil, nil, classifyQueryError(err)
+	}
+	node, err := query.Get(e.queried(), toks)
+	if err != nil {
+		return nil, nil, classifyQueryError(err)
 	}
-	return currentDoc
+	return jsonOf(node), toks, nil
 }
 
 // Run runs explorer in interactive mode
-func (e *Explorer) Run() *simplejson.Json {
-	err := termbox.Init()
-	defer termbox.Close()
+func (e *Explorer) Run() (*simplejson.Json, error) {
+	err := e.screen.Init()
 	if err != nil {
-		termboxFatalf("failed to initialize termbox: %s", err.Error())
+		return nil, errors.Wrap(err, "failed to initialize screen")
 	}
+	defer e.screen.Close()
+	e.screen.SetOutputMode(e.theme.OutputMode())
+	e.screen.SetInputMode(termbox.InputEsc | termbox.InputMouse)
 	e.display.Doc = e.doc
 	e.query.SetRaw("")
+	e.query.QueryPos = 0
 	e.syncWithQuery()
-	termbox.Flush()
+	e.drawStatusBar()
+	e.screen.Flush()
+	if e.follow != nil && !e.follow.started {
+		// interrupts can be sent only after the screen is initialized
+		e.follow.started = true
+		go e.follow.read(e.screen.Interrupt)
+	}
 	for {
-		switch ev := termbox.PollEvent(); ev.Type {
+		switch ev := e.screen.PollEvent(); ev.Type {
 		case termbox.EventKey:
-			switch ev.Key {
-			case 0:
-				e.symbolInput(ev.Ch)
-
-			case termbox.KeyCtrlV:
-				e.nextScreen()
-
-			case termbox.KeyCtrlO:
-				e.previousScreen()
-
-			case termbox.KeyCtrlT:
-				e.scrollToTop()
-
-			case termbox.KeyCtrlR:
-				e.scrollToBottom()
-
-			case termbox.KeyBackspace, termbox.KeyBackspace2:
-				e.display.ActiveCompletion = -1
-				e.query.DeleteCurrentChar()
-				e.syncWithQuery()
-
-			case termbox.KeyTab:
-				e.tabComplete()
-
-			case termbox.KeyCtrlP, termbox.KeyArrowUp:
-				e.scrollUp()
-
-			case termbox.KeyCtrlN, termbox.KeyArrowDown:
-				e.scrollDown()
-
-			case termbox.KeyCtrlF, termbox.KeyArrowRight:
-				e.cursorForwards()
-
-			case termbox.KeyCtrlB, termbox.KeyArrowLeft:
-				e.cursorBackwards()
-
-			case termbox.KeyCtrlK:
-				e.display.ActiveCompletion = -1
-				e.deleteAfterCursor()
-
-			case termbox.KeyCtrlG, termbox.KeyEsc:
-				e.display.ActiveCompletion = -1
-				e.drawCompletions()
-
-			case termbox.KeyCtrlU:
-				e.display.ActiveCompletion = -1
-				e.deleteBeforeCursor()
-
-			case termbox.KeyCtrlL:
-				e.display.ActiveCompletion = -1
-				e.display.OnlyKeys = !e.display.OnlyKeys
-				e.drawContents(true)
-
-			case termbox.KeyEnter:
-				res := e.processEnter()
-				if res != nil {
-					return res
-				}
-
-			case termbox.KeyCtrlC:
-				termboxFatalln("stopped with Ctrl+C")
-
+			e.message = ""
+			e.keyInput(ev)
+			if e.stop != nil {
+				return nil, e.stop
+			}
+			if e.result != nil {
+				return e.result, nil
 			}
+		case termbox.EventMouse:
+			e.mouseInput(ev)
+		case termbox.EventResize:
+			e.resize(ev.Width, ev.Height)
+		case termbox.EventError:
+			return nil, ev.Err
+		case termbox.EventInterrupt:
+			e.interrupted()
 		default:
 			e.fullRedraw()
 		}
-		termbox.Flush()
+		e.drawStatusBar()
+		e.screen.Flush()
 	}
 }
 
@@ -154,16 +197,36 @@ func (e *Explorer) tabComplete() {
 	if e.display.ActiveCompletion >= len(e.completions) {
 		e.display.ActiveCompletion = 0
 	}
-	e.drawCompletions()
+	e.fullRedraw()
+}
+
+func (e *Explorer) deleteChar() {
+	e.display.ActiveCompletion = -1
+	e.query.DeleteCurrentChar()
+	e.syncWithQuery()
+}
+
+func (e *Explorer) hideCompletions() {
+	e.display.ActiveCompletion = -1
+	e.fullRedraw()
+}
+
+func (e *Explorer) toggleOnlyKeys() {
+	e.display.ActiveCompletion = -1
+	e.display.OnlyKeys = !e.display.OnlyKeys
+	e.display.Shape = false
+	e.drawContents(true)
 }
 
 func (e *Explorer) deleteAfterCursor() {
+	e.display.ActiveCompletion = -1
 	beforeCursor := e.query.Raw()[:e.query.QueryPos]
 	e.query.SetRaw(beforeCursor)
 	e.syncWithQuery()
 }
 
 func (e *Explorer) deleteBeforeCursor() {
+	e.display.ActiveCompletion = -1
 	afterCursor := e.query.Raw()[e.query.QueryPos:]
 	e.query.SetRaw(afterCursor)
 	e.query.QueryPos = 0
@@ -175,7 +238,7 @@ func (e *Explorer) cursorBackwards() {
 		return
 	}
 	e.query.QueryPos--
-	termbox.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
+	e.screen.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
 }
 
 func (e *Explorer) cursorForwards() {
@@ -183,17 +246,36 @@ func (e *Explorer) cursorForwards() {
 		return
 	}
 	e.query.QueryPos++
-	termbox.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
+	e.screen.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
+}
+
+func (e *Explorer) cursorHome() {
+	e.query.QueryPos = 0
+	e.screen.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
+}
+
+func (e *Explorer) cursorEnd() {
+	e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+	e.screen.SetCursor(utf8.RuneCountInString(prompt)+e.query.QueryPos, promptY)
+}
+
+func (e *Explorer) interrupt() {
+	if e.dirty() && !e.confirmQuit {
+		e.confirmQuit = true
+		e.message = "unsaved changes, Ctrl+C again to quit without saving"
+		return
+	}
+	e.stop = &Error{Kind: Interrupted, Err: errors.New("stopped with Ctrl+C")}
 }
 
-func (e *Explorer) processEnter() *simplejson.Json {
+func (e *Explorer) processEnter() {
 	if e.display.ActiveCompletion == -1 {
-		return e.display.Doc
+		e.result = e.exported(e.display.Doc, e.docPath)
+		return
 	}
 	e.query.CompleteWith(e.completions[e.display.ActiveCompletion])
 	e.syncWithQuery()
 	e.display.ActiveCompletion = -1
-	return nil
 }
 
 func (e *Explorer) scrollToTop() {
@@ -202,22 +284,17 @@ func (e *Explorer) scrollToTop() {
 }
 
 func (e *Explorer) scrollToBottom() {
-	_, windowHeight := termbox.Size()
-	e.display.MoveWindow(e.display.DocHeight, windowHeight)
+	e.display.MoveWindow(e.display.DocHeight, e.layout.ContentHeight)
 	e.drawContents(true)
 }
 
 func (e *Explorer) nextScreen() {
-	_, windowHeight := termbox.Size()
-	contentsHeight := windowHeight - (completionY + 1)
-	e.display.MoveWindow(contentsHeight, windowHeight)
+	e.display.MoveWindow(e.layout.ContentHeight, e.layout.ContentHeight)
 	e.drawContents(true)
 }
 
 func (e *Explorer) previousScreen() {
-	_, windowHeight := termbox.Size()
-	contentsHeight := windowHeight - (completionY + 1)
-	e.display.MoveWindow(-contentsHeight, windowHeight)
+	e.display.MoveWindow(-e.layout.ContentHeight, e.layout.ContentHeight)
 	e.drawContents(true)
 }
 
@@ -230,66 +307,59 @@ func (e *Explorer) scrollUp() {
 }
 
 func (e *Explorer) scrollDown() {
-	_, windowHeight := termbox.Size()
-	e.display.MoveWindow(1, windowHeight)
+	e.display.MoveWindow(1, e.layout.ContentHeight)
 	e.drawContents(true)
 }
 
 func (e *Explorer) syncWithQuery() {
-	var full bool
-	e.display.Doc, full = traverse(e.doc, e.query.Parsed)
+	node, match := query.Eval(e.queried(), e.query.Parsed)
+	e.queryErr = queryError(e.query, e.queried(), match)
+	e.display.Doc = nil
+	if match != query.NoMatch {
+		e.display.Doc = jsonOf(node)
+	}
+	e.docPath = e.query.Parsed
 	e.completions = nil
-	if !full {
-		e.completions = completionsFor(e.display.Doc, e.query.Parsed)
-		if e.completions == nil {
+	if match == query.Partial {
+		e.docPath = e.query.Parsed[:len(e.query.Parsed)-1]
+		e.completions = query.Completions(node, e.query.Parsed)
+		last := e.query.Parsed[len(e.query.Parsed)-1]
+		if e.completions == nil && last != query.Token(query.Key("")) {
 			e.display.Doc = nil
 		}
 	}
+	if e.display.Doc != nil && e.hidden() {
+		e.display.Doc = jsonOf(e.masked(node, e.docPath))
+	}
 	e.fullRedraw()
 }
 
-func (e *Explorer) fullRedraw() {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// resize recomputes the layout for the new screen size keeping the same line
+// of the document at the top of the window when possible
+func (e *Explorer) resize(width, height int) {
+	e.relayout(width, height)
+	e.display.Clamp(e.layout.ContentHeight)
+	e.screen.Clear(termbox.ColorDefault, termbox.ColorDefault)
 	e.drawQueryLine()
 	e.drawCompletions()
 	e.drawContents(false)
 }
 
-func traverse(doc *simplejson.Json, path []Token) (*simplejson.Json, bool) {
-	jdoc := doc
-	for step, key := range path {
-		switch t := key.(type) {
-		case Index:
-			i := int(t)
-			newDoc, ok := checkGetIndex(jdoc, i)
-			if !ok {
-				return nil, false
-			}
-			jdoc = newDoc
-		case Key:
-			k := string(t)
-			newDoc, ok := jdoc.CheckGet(k)
-			if !ok {
-				if step == len(path)-1 {
-					return jdoc, false
-				}
-				return nil, false
-			}
-			jdoc = newDoc
-		case ErrIndex:
-			return jdoc, false
-		case ErrKey:
-			return nil, false
-		}
+func (e *Explorer) relayout(width, height int) {
+	complLines := 1
+	if e.completionsShown() {
+		complLines = len(wrapCompletions(e.completions, width))
 	}
-	return jdoc, true
+	e.layout = NewLayout(width, height, complLines, true)
 }
 
-func checkGetIndex(json *simplejson.Json, i int) (*simplejson.Json, bool) {
-	if arr, err := json.Array(); err != nil {
-		return nil, false
-	} else if len(arr) <= i || i < 0 {
-		return nil, false
-	}
-	return json.GetIndex(i), true
+func (e *Explorer) fullRedraw() {
+	e.resize(e.screen.Size())
+}
+
+// jsonOf wraps a decoded JSON value
+func jsonOf(v interface{}) *simplejson.Json {
+	j := simplejson.New()
+	j.SetPath(nil, v)
+	return j
 }
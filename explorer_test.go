@@ -0,0 +1,219 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func typeText(s string) []termbox.Event {
+	var evs []termbox.Event
+	for _, ch := range s {
+		evs = append(evs, termbox.Event{Type: termbox.EventKey, Ch: ch})
+	}
+	return evs
+}
+
+func key(k termbox.Key) termbox.Event {
+	return termbox.Event{Type: termbox.EventKey, Key: k}
+}
+
+func script(evs ...interface{}) []termbox.Event {
+	var all []termbox.Event
+	for _, ev := range evs {
+		switch t := ev.(type) {
+		case string:
+			all = append(all, typeText(t)...)
+		case termbox.Key:
+			all = append(all, key(t))
+		case termbox.Event:
+			all = append(all, t)
+		}
+	}
+	return all
+}
+
+// runScript runs the explorer on a screen of 40x12 cells feeding it the events
+func runScript(t *testing.T, doc string, evs ...interface{}) (*Explorer, *memScreen, *simplejson.Json, error) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(doc), '.', theme)
+	assert.NoError(t, err)
+	scr := newMemScreen(40, 12, script(evs...)...)
+	e.screen = scr
+	res, err := e.Run()
+	return e, scr, res, err
+}
+
+func TestExplorer_render(t *testing.T) {
+	_, scr, _, err := runScript(t, `{"b": [1, 2], "a": "str"}`)
+	assert.Equal(t, io.EOF, err)
+	// the first completion is suggested after the cursor
+	assert.Equal(t, []string{
+		">>> a",
+		"",
+		"{",
+		`  "a": "str",`,
+		`  "b": [`,
+		"     1,",
+		"     2",
+		"  ]",
+		"}",
+		"",
+		"",
+	}, scr.Lines()[:11])
+	assert.Equal(t, " object · 2 keys · 21 B  json  1/7 100%", scr.Line(11))
+	assert.Equal(t, 4, scr.cursorX)
+}
+
+func TestExplorer_queryAndEnter(t *testing.T) {
+	e, scr, res, err := runScript(t, `{"a": {"b": [10, 20]}}`, "a.b[1]", termbox.KeyEnter)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, res.MustInt())
+	assert.Equal(t, "a.b[1]", e.query.Raw())
+	assert.Equal(t, ">>> a.b[1]", scr.Line(0))
+	assert.Equal(t, 10, scr.cursorX)
+}
+
+func TestExplorer_completionCycling(t *testing.T) {
+	doc := `{"alpha": 1, "alps": 2, "beta": 3}`
+	_, scr, _, _ := runScript(t, doc, "al", termbox.KeyTab)
+	assert.Equal(t, ">>> alpha", scr.Line(0))
+	assert.Equal(t, "alpha alps", scr.Line(1))
+	assert.NotZero(t, scr.Cell(0, 1).Fg&termbox.AttrBold)
+	assert.Zero(t, scr.Cell(6, 1).Fg&termbox.AttrBold)
+
+	_, scr, _, _ = runScript(t, doc, "al", termbox.KeyTab, termbox.KeyTab)
+	assert.Zero(t, scr.Cell(0, 1).Fg&termbox.AttrBold)
+	assert.NotZero(t, scr.Cell(6, 1).Fg&termbox.AttrBold)
+
+	e, _, res, err := runScript(t, doc, "al", termbox.KeyTab, termbox.KeyTab, termbox.KeyEnter, termbox.KeyEnter)
+	assert.NoError(t, err)
+	assert.Equal(t, "alps", e.query.Raw())
+	assert.Equal(t, 2, res.MustInt())
+
+	e, _, _, _ = runScript(t, doc, "b", termbox.KeyTab)
+	assert.Equal(t, "beta", e.query.Raw())
+}
+
+func TestExplorer_multiSelect(t *testing.T) {
+	doc := `[{"id": 1, "name": "a", "size": 5}, {"id": 2, "nick": "b"}]`
+	// keys of all the elements are offered except the picked ones
+	_, scr, _, _ := runScript(t, doc, "[*].{id,", termbox.KeyTab)
+	assert.Equal(t, "name nick size", scr.Line(1))
+
+	e, _, res, err := runScript(t, doc, "[*].{id,n", termbox.KeyTab, termbox.KeyEnter, "}", termbox.KeyEnter)
+	assert.Equal(t, "[*].{id,name}", e.query.Raw())
+	assert.NoError(t, err)
+	out, _ := res.Encode()
+	assert.Equal(t, `[{"id":1,"name":"a"},{"id":2,"name":null}]`, string(out))
+}
+
+func TestExplorer_funcs(t *testing.T) {
+	doc := `{"b": [3, 1, 3]}`
+	e, scr, _, _ := runScript(t, doc, "b|len", termbox.KeyTab)
+	assert.Equal(t, "b|length", e.query.Raw())
+	assert.Equal(t, " 3", scr.Line(2))
+
+	_, _, res, err := runScript(t, doc, "b|unique", termbox.KeyEnter)
+	assert.NoError(t, err)
+	out, _ := res.Encode()
+	assert.Equal(t, "[1,3]", string(out))
+
+	_, scr, _, _ = runScript(t, doc, "b|lenx")
+	assert.Contains(t, scr.Line(11), "column 3: unknown fun")
+}
+
+func TestExplorer_aggregations(t *testing.T) {
+	doc := `{"e": [{"status": 200, "ms": 9007199254740993}, {"status": 500, "ms": 1}]}`
+	// the result is updated on every key
+	e, scr, _, _ := runScript(t, doc, "e[*].ms|sum")
+	assert.Equal(t, " 9007199254740994", scr.Line(2))
+
+	e, scr, _, _ = runScript(t, doc, "e|count_by(st", termbox.KeyTab, ")")
+	assert.Equal(t, "e|count_by(status)", e.query.Raw())
+	assert.Equal(t, []string{"{", `  "200": 1,`, `  "500": 1`, "}"}, scr.Lines()[2:6])
+}
+
+func TestExplorer_scrolling(t *testing.T) {
+	doc := `[` + strings.TrimSuffix(strings.Repeat("0,", 30), ",") + `]`
+	// 32 lines of the document, 9 lines of the window
+	_, scr, _, _ := runScript(t, doc, termbox.KeyCtrlN, termbox.KeyCtrlN, termbox.KeyArrowDown)
+	assert.Equal(t, "   0,", scr.Line(2))
+	assert.Contains(t, scr.Line(11), "4/32 37%")
+
+	// the window stops one line after the end of the document
+	_, scr, _, _ = runScript(t, doc, termbox.KeyCtrlR)
+	assert.Equal(t, "   0", scr.Line(8))
+	assert.Equal(t, "]", scr.Line(9))
+	assert.Equal(t, "", scr.Line(10))
+	assert.Contains(t, scr.Line(11), "25/32 100%")
+
+	e, _, _, _ := runScript(t, doc, termbox.KeyCtrlR, termbox.KeyCtrlO, termbox.KeyArrowUp)
+	assert.Equal(t, 14, e.display.DocOffsetY)
+	e, _, _, _ = runScript(t, doc, termbox.KeyCtrlV, termbox.KeyCtrlT)
+	assert.Equal(t, 0, e.display.DocOffsetY)
+}
+
+func TestExplorer_resize(t *testing.T) {
+	doc := `[` + strings.TrimSuffix(strings.Repeat("0,", 30), ",") + `]`
+	e, scr, _, _ := runScript(t, doc, termbox.KeyCtrlV, termbox.KeyCtrlV,
+		termbox.Event{Type: termbox.EventResize, Width: 30, Height: 6})
+	assert.Equal(t, 18, e.display.DocOffsetY)
+	assert.Equal(t, 3, e.layout.ContentHeight)
+	assert.Contains(t, scr.Line(5), "19/32")
+
+	e, _, _, _ = runScript(t, doc, termbox.KeyCtrlR,
+		termbox.Event{Type: termbox.EventResize, Width: 30, Height: 30})
+	assert.Equal(t, 6, e.display.DocOffsetY)
+}
+
+func TestExplorer_onlyKeys(t *testing.T) {
+	_, scr, _, _ := runScript(t, `{"b": 1, "a": {"c": 2}}`, termbox.KeyCtrlL)
+	assert.Equal(t, []string{"a", "b", ""}, scr.Lines()[2:5])
+	assert.Contains(t, scr.Line(11), "keys")
+}
+
+func TestExplorer_noResults(t *testing.T) {
+	_, scr, _, _ := runScript(t, `{"a": 1}`, "x.y")
+	assert.Equal(t, "--- no results ---", scr.Line(2))
+	assert.Contains(t, scr.Line(11), "path not found")
+}
+
+func TestExplorer_mouse(t *testing.T) {
+	doc := `{"a": {"b": 1}, "c": 2}`
+	click := func(x, y int) termbox.Event {
+		return termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseLeft, MouseX: x, MouseY: y}
+	}
+	e, _, _, _ := runScript(t, doc, click(4, 4))
+	assert.Equal(t, "a.b", e.query.Raw())
+
+	e, _, _, _ = runScript(t, `{"alpha": 1, "alps": 2}`, "a", termbox.KeyTab, click(7, 1))
+	assert.Equal(t, "alps", e.query.Raw())
+}
+
+func TestExplorer_help(t *testing.T) {
+	e, scr, _, _ := runScript(t, `{"a": 1}`, "a", termbox.KeyF1)
+	assert.Equal(t, "KEYBINDINGS", scr.Line(2))
+	assert.Contains(t, scr.Line(11), "help")
+	assert.Equal(t, "a", e.query.Raw())
+
+	e, scr, _, _ = runScript(t, `{"a": 1}`, "a", termbox.KeyF1, termbox.KeyArrowDown, termbox.KeyEsc)
+	assert.Nil(t, e.help)
+	assert.Equal(t, " 1", scr.Line(2))
+	assert.Equal(t, "a", e.query.Raw())
+
+	e, _, _, _ = runScript(t, `{"a": 1}`, "?")
+	assert.NotNil(t, e.help)
+	assert.Equal(t, "", e.query.Raw())
+}
+
+func TestExplorer_interrupt(t *testing.T) {
+	_, _, res, err := runScript(t, `{"a": 1}`, "a", termbox.KeyCtrlC, "more")
+	assert.Nil(t, res)
+	assert.Equal(t, Interrupted, err.(*Error).Kind)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/qoops-1/vuje/query"
+)
+
+// follower reads the records of a stream of JSON lines on a background goroutine.
+// The records read since the last interrupt of the screen are taken by the explorer
+type follower struct {
+	r       io.Reader
+	started bool
+	// paused stops scrolling to the newest records, it is only used by the explorer
+	paused bool
+
+	mu      sync.Mutex
+	records []interface{}
+	// skipped counts the lines that are not JSON
+	skipped int
+	// err is set when the stream ends, it is io.EOF if the stream was read to the end
+	err error
+	// notified is set while an interrupt for the pending records is on its way
+	notified bool
+}
+
+// Follow makes the document an array of the records read from the stream, one JSON
+// value per line. Records are added as they arrive while the explorer runs
+func (e *Explorer) Follow(stream io.Reader) {
+	e.setDoc([]interface{}{})
+	e.source = nil
+	e.follow = &follower{r: stream}
+}
+
+// read reads the records until the stream ends, notify is called when there are new records
+func (f *follower) read(notify func()) {
+	br := bufio.NewReader(f.r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			rec, perr := simplejson.NewJson(line)
+			f.mu.Lock()
+			if perr != nil {
+				f.skipped++
+			} else {
+				f.records = append(f.records, rec.Interface())
+			}
+			f.mu.Unlock()
+		}
+		f.mu.Lock()
+		if err != nil {
+			f.err = err
+		}
+		// one interrupt at a time is enough, the explorer takes all the pending records
+		send := !f.notified || err != nil
+		f.notified = true
+		f.mu.Unlock()
+		if send {
+			notify()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// take returns the records read since the last call
+func (f *follower) take() []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records := f.records
+	f.records = nil
+	f.notified = false
+	return records
+}
+
+// status describes the stream for the status bar
+func (f *follower) status(count int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg := plural(count, "record")
+	switch {
+	case f.err == io.EOF:
+		msg += ", stream ended"
+	case f.err != nil:
+		msg += fmt.Sprintf(", stream failed: %s", f.err)
+	case f.paused:
+		msg += ", paused, F9 to follow"
+	default:
+		msg += ", following"
+	}
+	if f.skipped > 0 {
+		msg += ", " + plural(f.skipped, "line") + " skipped, not JSON"
+	}
+	return msg
+}
+
+// ingest appends the new records of the followed stream to the document, applies the
+// query to them and scrolls to the end unless following is paused
+func (e *Explorer) ingest() {
+	records := e.follow.take()
+	if len(records) == 0 {
+		return
+	}
+	arr, ok := e.doc.Interface().([]interface{})
+	if !ok {
+		e.message = "the document is not an array anymore, new records are dropped"
+		return
+	}
+	from := len(arr)
+	e.appendRecords(append(arr, records...), from)
+	if !e.extendResult(from) {
+		e.syncWithQuery()
+		if e.display.ActiveCompletion >= len(e.completions) {
+			e.display.ActiveCompletion = -1
+		}
+	}
+	if !e.follow.paused {
+		e.scrollToBottom()
+	}
+}
+
+// appendRecords makes the array with the records appended from the index on the document.
+// Unlike setDoc, only the new records are validated and decoded. The shapes and the diffs
+// summarize the whole array, they are dropped and computed again only while they are shown
+func (e *Explorer) appendRecords(arr []interface{}, from int) {
+	e.doc = jsonOf(arr)
+	e.shapes = nil
+	e.diffs = nil
+	if e.embedded != nil {
+		e.embedded.extend(arr, from)
+	}
+	if e.validator == nil {
+		return
+	}
+	e.noted = nil
+	if vs, ok := e.validator.ValidateAppended(arr, from); ok {
+		e.violations = append(e.violations, vs...)
+	} else {
+		e.violations = e.validator.Validate(arr)
+	}
+}
+
+// extendResult updates the displayed result with the records appended from the index on
+// without evaluating the query over the whole document. The records are added to the
+// result of the root query and of queries that project every record, e.g. "[*].level",
+// and queries of a single record, e.g. "[3].msg", do not change. It reports false if the
+// whole document has to be queried again
+func (e *Explorer) extendResult(from int) bool {
+	path := e.query.Parsed
+	if len(path) > 0 && path[0] == query.Token(query.Key("")) {
+		path = path[1:]
+	}
+	if e.display.Doc == nil || e.other != nil {
+		return false
+	}
+	if len(path) == 0 {
+		return e.appendResult(from, nil)
+	}
+	switch t := path[0].(type) {
+	case query.Index:
+		// the rest of the query only sees the record
+		return t >= 0 && int(t) < from
+	case query.Wildcard:
+	default:
+		return false
+	}
+	if len(e.docPath) != len(e.query.Parsed) {
+		return false
+	}
+	for _, tok := range path[1:] {
+		switch tok.(type) {
+		case query.Call, query.ErrCall:
+			// functions apply to the whole projection
+			return false
+		}
+	}
+	if result, ok := e.display.Doc.Interface().([]interface{}); !ok || len(result) == 0 {
+		return false
+	}
+	return e.appendResult(from, path[1:])
+}
+
+// appendResult adds the nodes the path selects in the new records to the displayed array
+func (e *Explorer) appendResult(from int, path []query.Token) bool {
+	result, ok := e.display.Doc.Interface().([]interface{})
+	if !ok {
+		return false
+	}
+	records := e.queried().([]interface{})
+	if len(result) > 0 && &result[0] == &records[0] {
+		// the result of the root query can be the array of the document, the first
+		// append copies it so that masked records do not end up in the document
+		result = result[:len(result):len(result)]
+	}
+	for _, rec := range records[from:] {
+		if node, match := query.Eval(rec, path); match == query.Full {
+			result = append(result, e.masked(node, e.query.Parsed))
+		}
+	}
+	e.display.Doc = jsonOf(result)
+	e.drawContents(true)
+	return true
+}
+
+// toggleFollow pauses or resumes scrolling to the newest records of the followed stream
+func (e *Explorer) toggleFollow() {
+	if e.follow == nil {
+		e.message = "no stream is followed, start vuje with -follow"
+		return
+	}
+	e.follow.paused = !e.follow.paused
+	if !e.follow.paused {
+		e.scrollToBottom()
+	}
+}
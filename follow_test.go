@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollower_read(t *testing.T) {
+	f := &follower{r: strings.NewReader("{\"a\": 1}\nnot json\n\n[2]\n3")}
+	notified := 0
+	f.read(func() { notified++ })
+	// the end of the stream is reported even while the records wait to be taken
+	assert.Equal(t, 2, notified)
+	assert.Equal(t, `[{"a":1},[2],3]`, compactJSON(f.take()))
+	assert.Equal(t, "5 records, stream ended, 1 line skipped, not JSON", f.status(5))
+	assert.Nil(t, f.take())
+}
+
+func TestExplorer_follow(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{}`), '.', theme)
+	assert.NoError(t, err)
+	e.Follow(strings.NewReader(""))
+	e.follow.started = true
+	e.follow.records = []interface{}{decode(t, `{"level": "info"}`), decode(t, `{"msg": "x"}`)}
+	interrupt := termbox.Event{Type: termbox.EventInterrupt}
+	e.screen = newMemScreen(40, 12, script("[*].level", interrupt)...)
+	e.Run()
+	assert.Equal(t, `["info"]`, compactJSON(e.display.Doc.Interface()))
+	assert.Equal(t, "2 records, following", e.follow.status(2))
+
+	// new records are projected by the query, the window follows the end unless paused
+	for i := 0; i < 10; i++ {
+		e.follow.records = append(e.follow.records, decode(t, `{"level": "debug"}`))
+	}
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("[*].level", interrupt)...)
+	e.Run()
+	assert.Equal(t, 12, len(e.doc.MustArray()))
+	assert.Equal(t, 11, len(e.display.Doc.MustArray()))
+	assert.Equal(t, 5, e.display.DocOffsetY)
+
+	e.follow.records = []interface{}{decode(t, `{"level": "warn"}`)}
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("[*].level", termbox.KeyF9, termbox.KeyCtrlT, interrupt)...)
+	e.Run()
+	assert.Equal(t, 12, len(e.display.Doc.MustArray()))
+	assert.Equal(t, 0, e.display.DocOffsetY)
+	assert.Equal(t, "13 records, paused, F9 to follow", e.follow.status(13))
+
+	// other queries are evaluated again
+	e.follow.records = []interface{}{decode(t, `{"level": "error"}`)}
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("[13]", interrupt)...)
+	e.Run()
+	assert.Equal(t, `{"level":"error"}`, compactJSON(e.display.Doc.Interface()))
+}
+
+func TestExplorer_followIncremental(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{}`), '.', theme)
+	assert.NoError(t, err)
+	e.Follow(strings.NewReader(""))
+	e.follow.started = true
+	v, err := NewValidator(decode(t, `{"type": "array", "items": {"required": ["level"]}}`))
+	assert.NoError(t, err)
+	e.SetValidator(v)
+	r, err := newRedactor(nil, nil)
+	assert.NoError(t, err)
+	e.SetRedactor(r)
+	e.follow.records = []interface{}{decode(t, `{"level": "info"}`)}
+	interrupt := termbox.Event{Type: termbox.EventInterrupt}
+	e.screen = newMemScreen(40, 12, script(interrupt)...)
+	e.Run()
+
+	// the root query gets the new records masked, the document keeps the secrets
+	e.follow.records = []interface{}{decode(t, `{"token": "t"}`), decode(t, `{"level": "warn"}`)}
+	e.ingest()
+	assert.Equal(t, `[{"level":"info"},{"token":"<redacted>"},{"level":"warn"}]`, compactJSON(e.display.Doc.Interface()))
+	assert.Equal(t, `[{"level":"info"},{"token":"t"},{"level":"warn"}]`, compactJSON(e.doc.Interface()))
+	assert.Equal(t, []string{`/1: required: missing key "level"`}, violationStrings(e.violations))
+
+	// queries of a single record are not evaluated again
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script("[1]")...)
+	e.Run()
+	node := e.display.Doc
+	e.follow.records = []interface{}{decode(t, `{}`)}
+	e.ingest()
+	assert.True(t, node == e.display.Doc)
+	assert.Equal(t, 4, len(e.doc.MustArray()))
+	assert.Len(t, e.violations, 2)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+
+	runewidth "github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+// helpView is the scrollable help screen shown over the contents. It is
+// also used for other read-only screens, name is shown as their mode
+type helpView struct {
+	name  string
+	lines []string
+	view  Display
+}
+
+// helpLines generates the help text from the keymap and the query separator
+func helpLines(keymap []binding, sep rune) []string {
+	var lines []string
+	width := 0
+	for _, b := range keymap {
+		if w := runewidth.StringWidth(keyNames(b.keys)); w > width {
+			width = w
+		}
+	}
+	lines = append(lines, "KEYBINDINGS", "")
+	group := ""
+	for _, b := range keymap {
+		if b.group != group {
+			if group != "" {
+				lines = append(lines, "")
+			}
+			group = b.group
+			lines = append(lines, group)
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %s", runewidth.FillRight(keyNames(b.keys), width), b.desc))
+	}
+	lines = append(lines, "", "QUERY SYNTAX", "")
+	for _, l := range querySyntax(sep) {
+		lines = append(lines, "  "+l)
+	}
+	lines = append(lines, "", "FUNCTIONS", "")
+	for _, f := range query.Funcs() {
+		lines = append(lines, fmt.Sprintf("  |%s  %s", runewidth.FillRight(f.Usage(), 14), f.Desc))
+	}
+	lines = append(lines, "", "Press Esc, q or ? to close the help")
+	return lines
+}
+
+// querySyntax summarizes the grammar accepted by query.Parse and the foreign queries read instead
+func querySyntax(sep rune) []string {
+	return []string{
+		fmt.Sprintf("key1%ckey2     value of key2 in the object at key1", sep),
+		"key[N]        N-th element of the array at key",
+		"[N]           N-th element of the root array",
+		fmt.Sprintf("\\%c \\[ \\\\      escape the separator, \"[\" and \"\\\" inside keys", sep),
+		"[\"a.b\"]       key in quotes, only the quote and \"\\\" are escaped inside",
+		"['a.b']       the same with single quotes",
+		fmt.Sprintf("key%c{a,b}     object with only the keys a and b of the object at key", sep),
+		fmt.Sprintf("key[*]%cname   name of every element of the array at key", sep),
+		fmt.Sprintf("[*]%c{a,b}     keys a and b of every element of the root array", sep),
+		"key|length    function applied to the value at key, see FUNCTIONS",
+		"/key/0        JSON Pointer, a query starting with \"/\" is read as one",
+		"$.key[0]      JSONPath of keys, indices and [*], a query starting with \"$.\" is read as one",
+		"[\"/key\"]      key starting with \"/\" or \"$.\" at the root, it has to be quoted",
+		"",
+		"The separator can be changed with the -d option.",
+		"Completions are offered for keys, indices and function names, press Tab to cycle through them.",
+	}
+}
+
+func (e *Explorer) showHelp() {
+	e.help = &helpView{name: "help", lines: helpLines(e.keymap, e.query.Sep)}
+	e.help.view.DocHeight = len(e.help.lines)
+	e.fullRedraw()
+}
+
+func (e *Explorer) hideHelp() {
+	e.help = nil
+	e.fullRedraw()
+}
+
+func (e *Explorer) helpInput(ev termbox.Event) {
+	view := &e.help.view
+	switch {
+	case ev.Key == termbox.KeyEsc, ev.Key == termbox.KeyCtrlG, ev.Key == termbox.KeyEnter,
+		ev.Key == termbox.KeyF1, ev.Ch == 'q', ev.Ch == '?':
+		e.hideHelp()
+		return
+	case ev.Key == termbox.KeyCtrlC:
+		e.interrupt()
+		return
+	case ev.Key == termbox.KeyArrowDown, ev.Key == termbox.KeyCtrlN, ev.Ch == 'j':
+		view.MoveWindow(1, e.layout.ContentHeight)
+	case ev.Key == termbox.KeyArrowUp, ev.Key == termbox.KeyCtrlP, ev.Ch == 'k':
+		view.MoveWindow(-1, e.layout.ContentHeight)
+	case ev.Key == termbox.KeyPgdn, ev.Key == termbox.KeyCtrlV, ev.Key == termbox.KeySpace:
+		view.MoveWindow(e.layout.ContentHeight, e.layout.ContentHeight)
+	case ev.Key == termbox.KeyPgup, ev.Key == termbox.KeyCtrlO:
+		view.MoveWindow(-e.layout.ContentHeight, e.layout.ContentHeight)
+	case ev.Key == termbox.KeyCtrlT, ev.Key == termbox.KeyHome:
+		view.DocOffsetY = 0
+	case ev.Key == termbox.KeyCtrlR, ev.Key == termbox.KeyEnd:
+		view.MoveWindow(view.DocHeight, e.layout.ContentHeight)
+	}
+	e.drawContents(true)
+}
+
+func (e *Explorer) drawHelp() {
+	view := &e.help.view
+	view.Clamp(e.layout.ContentHeight)
+	for i := 0; i < e.layout.ContentHeight && view.DocOffsetY+i < len(e.help.lines); i++ {
+		line := e.help.lines[view.DocOffsetY+i]
+		fg := termbox.ColorDefault
+		if len(line) > 0 && line[0] != ' ' {
+			fg = e.theme.Attr(e.theme.Field)
+		}
+		e.drawString(e.layout.ContentY+i, line, fg, termbox.ColorDefault)
+	}
+}
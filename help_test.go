@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyName(t *testing.T) {
+	assert.Equal(t, "Ctrl+A", keyName(termbox.KeyCtrlA))
+	assert.Equal(t, "Ctrl+L", keyName(termbox.KeyCtrlL))
+	assert.Equal(t, "Tab", keyName(termbox.KeyTab))
+	assert.Equal(t, "Backspace", keyName(termbox.KeyCtrlH))
+	assert.Equal(t, "F1", keyName(termbox.KeyF1))
+	assert.Equal(t, "F12", keyName(termbox.KeyF12))
+	assert.Equal(t, "Backspace", keyNames([]termbox.Key{termbox.KeyBackspace, termbox.KeyBackspace2}))
+}
+
+func TestHelpLines(t *testing.T) {
+	keymap := defaultKeymap()
+	help := strings.Join(helpLines(keymap, '/'), "\n")
+	for _, b := range keymap {
+		assert.Contains(t, help, keyNames(b.keys)+"  ")
+		assert.Contains(t, help, b.desc)
+	}
+	assert.Contains(t, help, "Ctrl+L")
+	assert.Contains(t, help, "key1/key2")
+}
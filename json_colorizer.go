@@ -8,52 +8,17 @@ import (
 	"github.com/nwidger/jsoncolor"
 )
 
-func colorizeJSON(data []byte) *[][]termbox.Cell {
+func colorizeJSON(data []byte, theme *Theme) *[][]termbox.Cell {
 	formatter := jsoncolor.NewFormatter()
 	result := &[][]termbox.Cell{[]termbox.Cell{}}
-	regular := &termboxSprintfFuncer{
-		fg:     termbox.ColorDefault,
-		bg:     termbox.ColorDefault,
-		output: result,
-	}
-
-	bold := &termboxSprintfFuncer{
-		fg:     termbox.AttrBold,
-		bg:     termbox.ColorDefault,
-		output: result,
-	}
-
-	blueBold := &termboxSprintfFuncer{
-		fg:     termbox.ColorBlue | termbox.AttrBold,
-		bg:     termbox.ColorDefault,
-		output: result,
-	}
-
-	green := &termboxSprintfFuncer{
-		fg:     termbox.ColorGreen,
-		bg:     termbox.ColorDefault,
-		output: result,
-	}
-
-	blackBold := &termboxSprintfFuncer{
-		fg:     termbox.ColorBlack | termbox.AttrBold,
-		bg:     termbox.ColorDefault,
-		output: result,
+	style := func(s Style) jsoncolor.SprintfFuncer {
+		return &termboxSprintfFuncer{
+			fg:     theme.Attr(s),
+			bg:     termbox.ColorDefault,
+			output: result,
+		}
 	}
-
-	formatter.SpaceColor = regular
-	formatter.CommaColor = bold
-	formatter.ColonColor = bold
-	formatter.ObjectColor = bold
-	formatter.ArrayColor = bold
-	formatter.FieldQuoteColor = blueBold
-	formatter.FieldColor = blueBold
-	formatter.StringQuoteColor = green
-	formatter.StringColor = green
-	formatter.TrueColor = regular
-	formatter.FalseColor = regular
-	formatter.NumberColor = regular
-	formatter.NullColor = blackBold
+	applyTheme(formatter, style, theme)
 
 	formatter.Format(ioutil.Discard, data)
 	return result
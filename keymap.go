@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// binding maps keys to an action of the explorer. Bindings are listed in
+// the help screen grouped by "group"
+type binding struct {
+	keys   []termbox.Key
+	group  string
+	desc   string
+	action func(e *Explorer)
+}
+
+func defaultKeymap() []binding {
+	return []binding{
+		{group: "Moving JSON contents", desc: "scroll one line down", keys: []termbox.Key{termbox.KeyCtrlN, termbox.KeyArrowDown}, action: (*Explorer).scrollDown},
+		{group: "Moving JSON contents", desc: "scroll one line up", keys: []termbox.Key{termbox.KeyCtrlP, termbox.KeyArrowUp}, action: (*Explorer).scrollUp},
+		{group: "Moving JSON contents", desc: "scroll one screen down", keys: []termbox.Key{termbox.KeyCtrlV, termbox.KeyPgdn}, action: (*Explorer).nextScreen},
+		{group: "Moving JSON contents", desc: "scroll one screen up", keys: []termbox.Key{termbox.KeyCtrlO, termbox.KeyPgup}, action: (*Explorer).previousScreen},
+		{group: "Moving JSON contents", desc: "scroll to top", keys: []termbox.Key{termbox.KeyCtrlT}, action: (*Explorer).scrollToTop},
+		{group: "Moving JSON contents", desc: "scroll to bottom", keys: []termbox.Key{termbox.KeyCtrlR}, action: (*Explorer).scrollToBottom},
+
+		{group: "Moving cursor in query line", desc: "move cursor one char forwards", keys: []termbox.Key{termbox.KeyCtrlF, termbox.KeyArrowRight}, action: (*Explorer).cursorForwards},
+		{group: "Moving cursor in query line", desc: "move cursor one char back", keys: []termbox.Key{termbox.KeyCtrlB, termbox.KeyArrowLeft}, action: (*Explorer).cursorBackwards},
+		{group: "Moving cursor in query line", desc: "move cursor to the beginning", keys: []termbox.Key{termbox.KeyCtrlA, termbox.KeyHome}, action: (*Explorer).cursorHome},
+		{group: "Moving cursor in query line", desc: "move cursor to the end", keys: []termbox.Key{termbox.KeyCtrlE, termbox.KeyEnd}, action: (*Explorer).cursorEnd},
+
+		{group: "Editing query", desc: "delete char before cursor", keys: []termbox.Key{termbox.KeyBackspace, termbox.KeyBackspace2}, action: (*Explorer).deleteChar},
+		{group: "Editing query", desc: "delete everything before cursor", keys: []termbox.Key{termbox.KeyCtrlU}, action: (*Explorer).deleteBeforeCursor},
+		{group: "Editing query", desc: "delete everything after cursor", keys: []termbox.Key{termbox.KeyCtrlK}, action: (*Explorer).deleteAfterCursor},
+		{group: "Editing query", desc: "autocomplete, cycle through completions", keys: []termbox.Key{termbox.KeyTab}, action: (*Explorer).tabComplete},
+		{group: "Editing query", desc: "hide completions", keys: []termbox.Key{termbox.KeyCtrlG, termbox.KeyEsc}, action: (*Explorer).hideCompletions},
+
+		{group: "Editing document", desc: "change the value of the current node", keys: []termbox.Key{termbox.KeyF2}, action: (*Explorer).editValue},
+		{group: "Editing document", desc: "rename the key of the current node", keys: []termbox.Key{termbox.KeyF3}, action: (*Explorer).renameKey},
+		{group: "Editing document", desc: "add a key to the current object or an element to the current array", keys: []termbox.Key{termbox.KeyF4, termbox.KeyInsert}, action: (*Explorer).insertNode},
+		{group: "Editing document", desc: "delete the current node", keys: []termbox.Key{termbox.KeyDelete}, action: (*Explorer).deleteNode},
+		{group: "Editing document", desc: "undo the last change", keys: []termbox.Key{termbox.KeyCtrlZ}, action: (*Explorer).undo},
+		{group: "Editing document", desc: "save the document to the input file", keys: []termbox.Key{termbox.KeyCtrlS}, action: (*Explorer).save},
+		{group: "Editing document", desc: "save the document to another file", keys: []termbox.Key{termbox.KeyF6}, action: (*Explorer).saveAs},
+
+		{group: "Other", desc: "show the query as jq, JSONPath and JSON Pointer or convert a pointer or JSONPath to a query", keys: []termbox.Key{termbox.KeyCtrlY}, action: (*Explorer).translate},
+		{group: "Other", desc: "show Go, TypeScript and JSON Schema types of the current node", keys: []termbox.Key{termbox.KeyCtrlW}, action: (*Explorer).showTypes},
+		{group: "Other", desc: "jump to the next violation of the schema given with -schema", keys: []termbox.Key{termbox.KeyF8}, action: (*Explorer).nextViolation},
+		{group: "Other", desc: "jump to the previous violation of the schema", keys: []termbox.Key{termbox.KeyF7}, action: (*Explorer).prevViolation},
+		{group: "Other", desc: "pause or resume scrolling to the newest records of the stream given with -follow", keys: []termbox.Key{termbox.KeyF9}, action: (*Explorer).toggleFollow},
+		{group: "Other", desc: "toggle decoding of strings holding JSON, the query steps into them", keys: []termbox.Key{termbox.KeyCtrlD}, action: (*Explorer).toggleDecode},
+		{group: "Other", desc: "show or hide what timestamps, base64 strings, JWTs and URL queries decode to", keys: []termbox.Key{termbox.KeyF5}, action: (*Explorer).toggleAnnotations},
+		{group: "Other", desc: "reveal or mask again the secrets hidden with -redact, printed results stay masked", keys: []termbox.Key{termbox.KeyF12}, action: (*Explorer).toggleReveal},
+		{group: "Other", desc: "toggle shape summary mode: key paths with their types, counts and examples", keys: []termbox.Key{termbox.KeyCtrlX}, action: (*Explorer).toggleShape},
+		{group: "Other", desc: "toggle keys-only mode", keys: []termbox.Key{termbox.KeyCtrlL}, action: (*Explorer).toggleOnlyKeys},
+		{group: "Other", desc: "show this help (\"?\" works when the query is empty)", keys: []termbox.Key{termbox.KeyF1}, action: (*Explorer).showHelp},
+		{group: "Other", desc: "select completion or exit printing the current node", keys: []termbox.Key{termbox.KeyEnter}, action: (*Explorer).processEnter},
+		{group: "Other", desc: "exit", keys: []termbox.Key{termbox.KeyCtrlC}, action: (*Explorer).interrupt},
+	}
+}
+
+func (e *Explorer) keyInput(ev termbox.Event) {
+	if ev.Key != termbox.KeyCtrlC {
+		e.confirmQuit = false
+	}
+	if e.prompt != nil {
+		e.promptInput(ev)
+		return
+	}
+	if e.help != nil {
+		e.helpInput(ev)
+		return
+	}
+	if ev.Key == termbox.KeySpace {
+		ev.Ch = ' '
+	}
+	if ev.Ch != 0 {
+		if ev.Ch == '?' && e.query.Raw() == "" {
+			e.showHelp()
+			return
+		}
+		e.symbolInput(ev.Ch)
+		return
+	}
+	for _, b := range e.keymap {
+		for _, k := range b.keys {
+			if k == ev.Key {
+				b.action(e)
+				return
+			}
+		}
+	}
+}
+
+// keyName returns a human readable name of the key
+func keyName(k termbox.Key) string {
+	switch k {
+	case termbox.KeyTab:
+		return "Tab"
+	case termbox.KeyEnter:
+		return "Enter"
+	case termbox.KeyEsc:
+		return "Esc"
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		return "Backspace"
+	case termbox.KeySpace:
+		return "Space"
+	case termbox.KeyArrowUp:
+		return "Up"
+	case termbox.KeyArrowDown:
+		return "Down"
+	case termbox.KeyArrowLeft:
+		return "Left"
+	case termbox.KeyArrowRight:
+		return "Right"
+	case termbox.KeyPgup:
+		return "PgUp"
+	case termbox.KeyPgdn:
+		return "PgDn"
+	case termbox.KeyHome:
+		return "Home"
+	case termbox.KeyEnd:
+		return "End"
+	case termbox.KeyDelete:
+		return "Delete"
+	case termbox.KeyInsert:
+		return "Insert"
+	}
+	if k >= termbox.KeyF12 && k <= termbox.KeyF1 {
+		return fmt.Sprintf("F%d", termbox.KeyF1-k+1)
+	}
+	if k >= termbox.KeyCtrlA && k <= termbox.KeyCtrlZ {
+		return fmt.Sprintf("Ctrl+%c", 'A'+rune(k-termbox.KeyCtrlA))
+	}
+	return fmt.Sprintf("0x%X", uint16(k))
+}
+
+// keyNames joins the names of the keys deduplicating keys with equal names
+func keyNames(keys []termbox.Key) string {
+	var names []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		name := keyName(k)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// maxCompletionShare limits the completion area to 1/maxCompletionShare of the screen
+const maxCompletionShare = 3
+
+// Layout holds the screen regions of the explorer. The query line is at the top,
+// then go wrapped completions, the contents and an optional status bar at the bottom
+type Layout struct {
+	Width            int
+	Height           int
+	PromptY          int
+	CompletionY      int
+	CompletionHeight int
+	ContentY         int
+	ContentHeight    int
+	// StatusY is -1 if there is no status bar
+	StatusY int
+}
+
+// NewLayout computes the regions for a screen of the given size. The completion
+// area takes from one line up to a third of the screen depending on "complLines"
+func NewLayout(width, height int, complLines int, statusBar bool) Layout {
+	l := Layout{
+		Width:       width,
+		Height:      height,
+		PromptY:     promptY,
+		CompletionY: promptY + 1,
+		StatusY:     -1,
+	}
+	maxCompl := height / maxCompletionShare
+	if complLines > maxCompl {
+		complLines = maxCompl
+	}
+	if complLines < 1 {
+		complLines = 1
+	}
+	l.CompletionHeight = complLines
+	l.ContentY = l.CompletionY + l.CompletionHeight
+	bottom := height
+	if statusBar && bottom > l.ContentY {
+		bottom--
+		l.StatusY = bottom
+	}
+	l.ContentHeight = bottom - l.ContentY
+	if l.ContentHeight < 0 {
+		l.ContentHeight = 0
+	}
+	return l
+}
+
+// wrapCompletions splits space separated completions into lines of at most "width" cells.
+// Every line holds the indices of its completions. A completion wider than the
+// screen takes a whole line
+func wrapCompletions(compls []string, width int) [][]int {
+	var lines [][]int
+	var line []int
+	lineWidth := 0
+	for i, c := range compls {
+		w := runewidth.StringWidth(c) + 1
+		if len(line) > 0 && lineWidth+w > width+1 {
+			lines = append(lines, line)
+			line, lineWidth = nil, 0
+		}
+		line = append(line, i)
+		lineWidth += w
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// visibleLines returns the first of "height" consecutive lines so that the line
+// containing the completion "active" is visible
+func visibleLines(lines [][]int, active int, height int) int {
+	for n, line := range lines {
+		if active >= line[0] && active <= line[len(line)-1] {
+			if n < height {
+				return 0
+			}
+			return n - height + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLayout(t *testing.T) {
+	l := NewLayout(80, 24, 1, false)
+	assert.Equal(t, Layout{
+		Width: 80, Height: 24,
+		PromptY: 0, CompletionY: 1, CompletionHeight: 1,
+		ContentY: 2, ContentHeight: 22, StatusY: -1,
+	}, l)
+
+	l = NewLayout(80, 24, 3, true)
+	assert.Equal(t, 4, l.ContentY)
+	assert.Equal(t, 19, l.ContentHeight)
+	assert.Equal(t, 23, l.StatusY)
+
+	// completions never take more than a third of the screen
+	l = NewLayout(80, 12, 10, false)
+	assert.Equal(t, 4, l.CompletionHeight)
+	assert.Equal(t, 7, l.ContentHeight)
+
+	// tiny screens leave no room for contents, but never a negative one
+	l = NewLayout(10, 2, 1, true)
+	assert.Equal(t, 0, l.ContentHeight)
+	assert.Equal(t, -1, l.StatusY)
+}
+
+func TestWrapCompletions(t *testing.T) {
+	compls := []string{"aaa", "bb", "cccc", "d", "verylongcompletion"}
+	assert.Equal(t, [][]int{{0, 1}, {2, 3}, {4}}, wrapCompletions(compls, 7))
+	assert.Equal(t, [][]int{{0, 1, 2, 3, 4}}, wrapCompletions(compls, 80))
+	assert.Nil(t, wrapCompletions(nil, 80))
+}
+
+func TestVisibleLines(t *testing.T) {
+	lines := [][]int{{0, 1}, {2, 3}, {4}}
+	assert.Equal(t, 0, visibleLines(lines, 1, 2))
+	assert.Equal(t, 0, visibleLines(lines, 3, 2))
+	assert.Equal(t, 1, visibleLines(lines, 4, 2))
+	assert.Equal(t, 2, visibleLines(lines, 4, 1))
+}
+
+func TestDisplay_resize(t *testing.T) {
+	dspl := &Display{DocHeight: 54}
+	resize := func(width, height int) {
+		dspl.Clamp(NewLayout(width, height, 1, false).ContentHeight)
+	}
+	resize(80, 24)
+	assert.Equal(t, 0, dspl.DocOffsetY)
+
+	dspl.DocOffsetY = 20
+	resize(80, 10)
+	assert.Equal(t, 20, dspl.DocOffsetY, "top line stays anchored")
+	resize(80, 50)
+	assert.Equal(t, 7, dspl.DocOffsetY, "window is pulled back to the end of the document")
+
+	contentsHeight := NewLayout(80, 50, 1, false).ContentHeight
+	assert.False(t, dspl.MoveWindow(5, contentsHeight))
+	assert.True(t, dspl.MoveWindow(-5, contentsHeight))
+	assert.Equal(t, 2, dspl.DocOffsetY)
+
+	// documents shorter than the window always start at the top
+	dspl = &Display{DocHeight: 5, DocOffsetY: 3}
+	resize(80, 24)
+	assert.Equal(t, 0, dspl.DocOffsetY)
+	assert.False(t, dspl.MoveWindow(1, 22))
+
+	// windows of one line or none stop at the last line
+	dspl.DocOffsetY = 4
+	dspl.Clamp(0)
+	assert.Equal(t, 4, dspl.DocOffsetY)
+	assert.False(t, dspl.MoveWindow(1, 1))
+	assert.True(t, dspl.MoveWindow(-1, 0))
+}
+
+func TestExplorer_tinyScreen(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	wheel := termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseWheelDown}
+	// the window never scrolls past the last line, even when there is no room for it
+	for height := 1; height <= 3; height++ {
+		for _, mode := range []interface{}{nil, termbox.KeyCtrlL, termbox.KeyCtrlX, termbox.KeyF1} {
+			e, err := NewExplorer(strings.NewReader(`{"a": [1, 2], "b": {"c": 3}}`), '.', theme)
+			assert.NoError(t, err)
+			evs := []interface{}{termbox.KeyArrowDown, termbox.KeyPgdn, wheel, termbox.KeyCtrlR, termbox.KeyPgdn, wheel}
+			if mode != nil {
+				evs = append([]interface{}{mode}, evs...)
+			}
+			e.screen = newMemScreen(20, height, script(evs...)...)
+			e.Run()
+			view := e.display
+			if e.help != nil {
+				view = &e.help.view
+			}
+			assert.True(t, view.DocOffsetY == 0 || view.DocOffsetY < view.DocHeight, "height %d", height)
+		}
+	}
+}
@@ -5,78 +5,506 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/bitly/go-simplejson"
 	"github.com/nwidger/jsoncolor"
-
-	termbox "github.com/nsf/termbox-go"
+	"github.com/pkg/errors"
+	"github.com/qoops-1/vuje/query"
 )
 
 const version = "0.0.1"
 
+// options are the command line options of the program
+type options struct {
+	rawQuery  string
+	separator string
+	themeName string
+	pretty    bool
+	// translateTo is the query language the query is printed in instead of executing it
+	translateTo string
+	// generate is the language of the types printed instead of the selected node
+	generate string
+	// schemaFile is the JSON Schema the input is validated against
+	schemaFile string
+	// validate prints the violations of the schema instead of exploring the input
+	validate bool
+	// diffFile is the document the input is compared with
+	diffFile string
+	// diffKey is the key array elements are matched by in the diff
+	diffKey string
+	// diffFormat is the format the differences are printed in instead of showing them side by side
+	diffFormat string
+	// patchFile is the JSON Patch or merge patch applied to the input before anything else
+	patchFile string
+	// mods are the -set and -del modifications in the order they are given
+	mods []modification
+	// inPlace writes the modified document back to the input file
+	inPlace bool
+	// watch reloads the input file when it changes
+	watch bool
+	// decode steps into the strings holding JSON objects and arrays
+	decode bool
+	// redact masks the secrets found by the default patterns and by redactKeys and redactValues
+	redact       bool
+	redactKeys   []string
+	redactValues []string
+	// follow reads the input as a stream of JSON lines, the records are shown as they arrive
+	follow bool
+	// file is the input file, the input is read from stdin if it is empty. Edits are saved to it
+	file string
+}
+
 func main() {
-	var query string
-	var separator string
-	var pretty bool
+	var opts options
+	var errorJSON bool
 	var ver bool
 	finfo, err := os.Stdout.Stat()
 	if err != nil {
-		log.Fatalln(err)
+		os.Exit(reportError(os.Stderr, &Error{Kind: OutputError, Err: err}, false))
 	}
 	pipedOutput := (finfo.Mode() & os.ModeCharDevice) == 0
-	flag.StringVar(&query, "s", "", "execute specified query in non-interactive mode and return results")
-	flag.StringVar(&separator, "d", ".", "specify custom separator for the query. Default is \".\"")
-	flag.BoolVar(&pretty, "p", !pipedOutput, "set to true if final output should be coloured. "+
+	flag.StringVar(&opts.rawQuery, "s", "", "execute specified query in non-interactive mode and return results")
+	flag.StringVar(&opts.separator, "d", ".", "specify custom separator for the query. Default is \".\"")
+	flag.BoolVar(&opts.pretty, "p", !pipedOutput, "set to true if final output should be coloured. "+
 		"By default the flag is set to true, but, if the output of the program is piped, it is set to false")
+	flag.StringVar(&opts.themeName, "t", "dark", "color theme: "+strings.Join(ThemeNames(), ", ")+". "+
+		"Colors are disabled when the NO_COLOR environment variable is set")
+	flag.StringVar(&opts.translateTo, "to", "", "print the query given with -s translated to jq, jsonpath or pointer "+
+		"instead of executing it. With vuje, a JSON Pointer or JSONPath is converted into a vuje query")
+	flag.StringVar(&opts.generate, "gen", "", "print the types of the node selected with -s, or of the whole input, "+
+		"as go structs, ts interfaces or a JSON schema")
+	flag.StringVar(&opts.schemaFile, "schema", "", "validate the input against the JSON Schema (draft 7 or 2020-12) "+
+		"in the file and show the violations next to the values")
+	flag.BoolVar(&opts.validate, "validate", false, "print the violations of the -schema instead of exploring the input. "+
+		"With -s only the violations at the selected node and below it are printed")
+	flag.StringVar(&opts.diffFile, "diff", "", "compare the input with the JSON document in the file side by side. "+
+		"The query selects the nodes of both documents")
+	flag.StringVar(&opts.diffKey, "diff-key", "", "match elements of arrays in -diff by the value of this key, e.g. id")
+	flag.StringVar(&opts.diffFormat, "diff-format", "", "print the differences found with -diff instead of showing them: "+
+		"text, json-patch or merge-patch. With -s the differences of the selected nodes are printed as text by default")
+	flag.StringVar(&opts.patchFile, "patch", "", "apply the JSON Patch (an array of operations) or the merge patch "+
+		"(an object) in the file to the input before exploring it")
+	flag.Var(modifications{list: &opts.mods}, "set", "set the value at the query to JSON, e.g. 'spec.replicas=3', "+
+		"or to the JSON document in a file with 'spec=@spec.json'. Wildcards set many values, missing objects are created. "+
+		"Can be repeated, the document is printed unless -s or -i is given")
+	flag.Var(modifications{list: &opts.mods, del: true}, "del", "delete the value at the query, can be repeated")
+	flag.BoolVar(&opts.inPlace, "i", false, "write the document modified with -set, -del or -patch back to the input file "+
+		"instead of printing it")
+	flag.BoolVar(&opts.watch, "watch", false, "reload the input file when it changes keeping the query, "+
+		"the changed values are highlighted for a moment")
+	flag.BoolVar(&opts.decode, "decode", false, "decode strings holding JSON objects or arrays, "+
+		"so that queries step into them and they are shown expanded. Ctrl+D toggles it in the explorer")
+	flag.BoolVar(&opts.redact, "redact", os.Getenv("VUJE_REDACT") != "", "mask passwords, tokens, keys and other secrets "+
+		"in the explorer and in the output. F12 reveals them in the explorer. Set by default when VUJE_REDACT is not empty")
+	flag.Var(listFlag{list: &opts.redactKeys, split: true}, "redact-key", "mask the values of the keys matching the pattern "+
+		"besides the default ones, \"*\" matches any text, e.g. '*_pin'. Can be repeated or list patterns separated by commas, implies -redact")
+	flag.Var(listFlag{list: &opts.redactValues}, "redact-value", "mask the parts of strings matching the regular expression "+
+		"besides AWS keys, JWTs and private keys. Can be repeated, implies -redact")
+	flag.BoolVar(&opts.follow, "follow", false, "read the input as a stream of JSON values, one per line, e.g. from "+
+		"'kubectl logs -f', and show the records as an array that grows while they arrive")
+	flag.BoolVar(&errorJSON, "error-json", false, "print errors to stderr as JSON objects")
 	flag.BoolVar(&ver, "v", false, "output version")
 	flag.BoolVar(&ver, "version", false, "output version")
+	flag.Usage = usage
 	flag.Parse()
 	if ver {
 		fmt.Println(version)
 		return
 	}
-	if len([]rune(separator)) != 1 {
-		log.Panicf("Separator must be a single character")
+	if flag.NArg() > 1 {
+		os.Exit(reportError(os.Stderr, usageErrorf("only one input file can be given, got %d", flag.NArg()), errorJSON))
+	}
+	opts.file = flag.Arg(0)
+	if err := run(opts); err != nil {
+		os.Exit(reportError(os.Stderr, err, errorJSON))
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [file.json]\n\nWithout a file the input is read from stdin\n\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(flag.CommandLine.Output(), `
+Exit codes:
+  %d  success
+  %d  usage error: bad options or query syntax
+  %d  input cannot be parsed as JSON
+  %d  query path not found in the document or a function failed
+  %d  output cannot be written
+  %d  the input does not match the schema
+  %d  an operation of the patch, -set or -del cannot be applied
+  %d  interrupted with Ctrl+C
+`, exitOK, exitUsage, exitInput, exitNotFound, exitOutput, exitInvalid, exitPatch, exitInterrupted)
+}
+
+func run(opts options) error {
+	if len([]rune(opts.separator)) != 1 {
+		return usageErrorf("separator must be a single character, got %q", opts.separator)
+	}
+	if opts.translateTo != "" {
+		if opts.rawQuery == "" {
+			return usageErrorf("-to requires a query given with -s")
+		}
+		var doc interface{}
+		if opts.file != "" && opts.translateTo == "vuje" && query.IsPointer(opts.rawQuery) {
+			// the document tells the numeric keys of objects from the indices of arrays
+			data, err := ioutil.ReadFile(opts.file)
+			if err != nil {
+				return &Error{Kind: InputError, Err: errors.Wrap(err, "cant read input")}
+			}
+			parsed, err := simplejson.NewJson(data)
+			if err != nil {
+				return inputError(data, err)
+			}
+			doc = parsed.Interface()
+		}
+		translated, err := translateQuery(opts.rawQuery, []rune(opts.separator)[0], opts.translateTo, doc)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Println(translated); err != nil {
+			return &Error{Kind: OutputError, Err: err}
+		}
+		return nil
+	}
+	if opts.validate && opts.schemaFile == "" {
+		return usageErrorf("-validate requires a schema given with -schema")
+	}
+	if (opts.diffKey != "" || opts.diffFormat != "") && opts.diffFile == "" {
+		return usageErrorf("-diff-key and -diff-format require a document given with -diff")
+	}
+	switch opts.diffFormat {
+	case "", "text", "json-patch", "merge-patch":
+	default:
+		return usageErrorf("unknown diff format %q, available formats: text, json-patch, merge-patch", opts.diffFormat)
+	}
+	if opts.inPlace && (opts.file == "" || len(opts.mods) == 0 && opts.patchFile == "") {
+		return usageErrorf("-i requires an input file and -set, -del or -patch")
+	}
+	if opts.watch && (opts.file == "" || opts.rawQuery != "" || len(opts.mods) > 0 || opts.validate || opts.generate != "") {
+		return usageErrorf("-watch requires an input file and the interactive mode")
+	}
+	if opts.follow && (opts.rawQuery != "" || len(opts.mods) > 0 || opts.patchFile != "" || opts.validate ||
+		opts.generate != "" || opts.diffFile != "" || opts.watch) {
+		return usageErrorf("-follow requires the interactive mode and cannot be used with -patch, -diff or -watch")
+	}
+	var redactor *redactor
+	if opts.redact || len(opts.redactKeys) > 0 || len(opts.redactValues) > 0 {
+		var err error
+		if redactor, err = newRedactor(opts.redactKeys, opts.redactValues); err != nil {
+			return &Error{Kind: UsageError, Err: err}
+		}
+	}
+	theme, err := detectTheme(opts.themeName)
+	if err != nil {
+		return &Error{Kind: UsageError, Err: err}
+	}
+	input := io.Reader(os.Stdin)
+	if opts.file != "" {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			return &Error{Kind: InputError, Err: errors.Wrap(err, "cant read input")}
+		}
+		defer f.Close()
+		input = f
+	}
+	stream := input
+	if opts.follow {
+		input = strings.NewReader("[]")
+	}
+	explorer, err := NewExplorer(bufio.NewReader(input), []rune(opts.separator)[0], theme)
+	if err != nil {
+		return err
+	}
+	if opts.follow {
+		// the stream is read while exploring, the records can be saved to another file only
+		explorer.Follow(stream)
+	} else {
+		explorer.SetFile(opts.file)
+	}
+	explorer.decode = opts.decode
+	if redactor != nil {
+		explorer.SetRedactor(redactor)
+	}
+	if opts.patchFile != "" {
+		patch, err := readJSONFile(opts.patchFile, "patch")
+		if err != nil {
+			return err
+		}
+		doc, err := applyPatch(explorer.doc.Interface(), patch.Interface())
+		if err != nil {
+			return &Error{Kind: PatchError, Err: errors.Wrapf(err, "patch %s", opts.patchFile)}
+		}
+		explorer.doc = jsonOf(doc)
+	}
+	if len(opts.mods) > 0 {
+		doc, err := modify(explorer.doc.Interface(), opts.mods, []rune(opts.separator)[0])
+		if err != nil {
+			return err
+		}
+		explorer.doc = jsonOf(doc)
+	}
+	if opts.inPlace {
+		return saveInPlace(explorer, opts.file)
+	}
+	if len(opts.mods) > 0 && opts.rawQuery == "" && opts.generate == "" && !opts.validate && opts.diffFile == "" {
+		return printDocument(explorer)
+	}
+	if opts.diffFile != "" {
+		other, err := readJSONFile(opts.diffFile, "document")
+		if err != nil {
+			return err
+		}
+		explorer.SetOther(other, opts.diffKey)
+		if opts.diffFormat != "" || opts.rawQuery != "" {
+			return printDiff(explorer, opts)
+		}
+	}
+	if opts.schemaFile != "" {
+		validator, err := loadSchema(opts.schemaFile)
+		if err != nil {
+			return err
+		}
+		explorer.SetValidator(validator)
+	}
+	if opts.validate {
+		return printViolations(explorer, opts)
+	}
+	if opts.generate != "" {
+		return printTypes(explorer, opts)
+	}
+	if opts.watch {
+		stop, err := explorer.Watch()
+		if err != nil {
+			return &Error{Kind: InputError, Err: err}
+		}
+		defer stop()
 	}
-	stdin := bufio.NewReader(os.Stdin)
-	explorer := NewExplorer(stdin, []rune(separator)[0])
 	var res *simplejson.Json
-	if query != "" {
-		res = explorer.ExecuteQuery(query)
+	if opts.rawQuery != "" {
+		res, err = explorer.ExecuteQuery(opts.rawQuery)
 	} else {
-		res = explorer.Run()
+		res, err = explorer.Run()
+	}
+	if err != nil {
+		return err
+	}
+	if err := printResult(res, opts.pretty, theme); err != nil {
+		return &Error{Kind: OutputError, Err: err}
 	}
-	printResult(res, pretty)
-	fmt.Println()
+	return nil
 }
 
-func printResult(res *simplejson.Json, pretty bool) {
-	if !pretty {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetEscapeHTML(false)
-		enc.SetIndent("", "  ")
-		enc.Encode(res.Interface())
-		return
+// saveInPlace replaces the input file with the document keeping the layout of the input
+func saveInPlace(explorer *Explorer, file string) error {
+	data, err := explorer.docFormat().encode(explorer.doc.Interface())
+	if err == nil {
+		err = writeFileAtomic(file, data)
 	}
-	binJSON, _ := res.Encode()
-	fmtr := jsoncolor.NewFormatter()
-	fmtr.Indent = "  "
-	err := fmtr.Format(os.Stdout, binJSON)
 	if err != nil {
-		log.Fatalln(err)
+		return &Error{Kind: OutputError, Err: errors.Wrapf(err, "cant save %s", file)}
 	}
+	return nil
 }
 
-func termboxFatalf(msg string, args ...interface{}) {
-	termbox.Close()
-	fmt.Fprintf(os.Stderr, msg, args...)
-	os.Exit(1)
+// printDocument prints the whole document in the layout of the input
+func printDocument(explorer *Explorer) error {
+	doc := explorer.exported(explorer.doc, nil)
+	data, err := explorer.docFormat().encode(doc.Interface())
+	if err == nil {
+		_, err = os.Stdout.Write(data)
+	}
+	if err != nil {
+		return &Error{Kind: OutputError, Err: err}
+	}
+	return nil
 }
 
-func termboxFatalln(vs ...interface{}) {
-	termbox.Close()
-	fmt.Fprintln(os.Stderr, vs...)
-	os.Exit(1)
+// printTypes prints the types generated from the node selected by the query
+func printTypes(explorer *Explorer, opts options) error {
+	node := explorer.doc
+	var path []query.Token
+	if opts.rawQuery != "" {
+		var err error
+		// types have no values, the secrets are not masked
+		if node, _, err = explorer.executeQuery(opts.rawQuery); err != nil {
+			return err
+		}
+		path, _ = query.Parse(opts.rawQuery, []rune(opts.separator)[0])
+	}
+	code, err := generateCode(node.Interface(), typeName(path), opts.generate)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Print(code); err != nil {
+		return &Error{Kind: OutputError, Err: err}
+	}
+	return nil
+}
+
+// readJSONFile reads the JSON document from the file, what names it in the errors
+func readJSONFile(name, what string) (*simplejson.Json, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, &Error{Kind: InputError, Err: errors.Wrapf(err, "cant read %s", what)}
+	}
+	doc, err := simplejson.NewJson(data)
+	if err != nil {
+		e := inputError(data, err)
+		e.Err = errors.Wrapf(e.Err, "%s %s", what, name)
+		return nil, e
+	}
+	return doc, nil
+}
+
+// loadSchema reads the JSON Schema from the file
+func loadSchema(name string) (*Validator, error) {
+	schema, err := readJSONFile(name, "schema")
+	if err != nil {
+		return nil, err
+	}
+	validator, err := NewValidator(schema.Interface())
+	if err != nil {
+		return nil, &Error{Kind: UsageError, Err: errors.Wrapf(err, "schema %s", name)}
+	}
+	return validator, nil
+}
+
+// printViolations prints the violations at the node selected by the query and below it
+func printViolations(explorer *Explorer, opts options) error {
+	var prefix []query.Token
+	if opts.rawQuery != "" {
+		var err error
+		if prefix, err = compileQuery(opts.rawQuery, []rune(opts.separator)[0], explorer.doc.Interface()); err != nil {
+			return classifyQueryError(err)
+		}
+	}
+	n := 0
+	for _, v := range explorer.violations {
+		if !within(v.Path, prefix) {
+			continue
+		}
+		n++
+		v.Msg = explorer.violationMsg(v)
+		if _, err := fmt.Println(v); err != nil {
+			return &Error{Kind: OutputError, Err: err}
+		}
+	}
+	if n > 0 {
+		return &Error{Kind: InvalidError, Err: errors.New(plural(n, "schema violation"))}
+	}
+	return nil
+}
+
+// printDiff prints the differences between the nodes selected by the query in both documents
+func printDiff(explorer *Explorer, opts options) error {
+	path := []query.Token{}
+	if opts.rawQuery != "" {
+		var err error
+		if path, err = compileQuery(opts.rawQuery, []rune(opts.separator)[0], explorer.doc.Interface()); err != nil {
+			return classifyQueryError(err)
+		}
+	}
+	old, oldErr := query.Get(explorer.doc.Interface(), path)
+	new, newErr := query.Get(explorer.other.Interface(), path)
+	if oldErr != nil && newErr != nil {
+		return classifyQueryError(oldErr)
+	}
+	if opts.diffFormat == "json-patch" || opts.diffFormat == "merge-patch" {
+		return printPatch(old, new, oldErr == nil, newErr == nil, path, opts, explorer.redactor)
+	}
+	var changes []Change
+	switch {
+	case oldErr != nil:
+		changes = []Change{{Kind: Added, Path: []query.Token{}, New: new}}
+	case newErr != nil:
+		changes = []Change{{Kind: Removed, Path: []query.Token{}, Old: old}}
+	default:
+		changes = diffValues(old, new, opts.diffKey)
+	}
+	if explorer.redactor != nil {
+		changes = explorer.redactor.redactChanges(path, changes)
+	}
+	if !constructed(path) {
+		changes = prefixChanges(path, changes)
+	}
+	if err := writePatch(os.Stdout, changes); err != nil {
+		return &Error{Kind: OutputError, Err: err}
+	}
+	return nil
+}
+
+// printPatch prints the patch that turns the old node at the path into the new one.
+// Unless the path is constructed, the pointers of the patch start at the root of the document.
+// The secrets are masked in the values of the patch when r is not nil
+func printPatch(old, new interface{}, inOld, inNew bool, path []query.Token, opts options, r *redactor) error {
+	if constructed(path) {
+		path = []query.Token{}
+	} else if len(path) > 0 && path[0] == query.Token(query.Key("")) {
+		path = path[1:]
+	}
+	var patch interface{}
+	if opts.diffFormat == "json-patch" {
+		ptr, _ := query.ToPointer(path)
+		switch {
+		case !inOld:
+			patch = []patchOp{{Op: "add", Path: ptr, Value: json.RawMessage(compactJSON(new))}}
+		case !inNew:
+			patch = []patchOp{{Op: "remove", Path: ptr}}
+		default:
+			patch = jsonPatch(old, new, path, opts.diffKey)
+		}
+	} else {
+		var err error
+		if inNew {
+			patch, err = mergePatch(old, new, path)
+		}
+		if err == nil {
+			patch, err = nestMergePatch(patch, path)
+		}
+		if _, ok := patch.([]interface{}); ok && err == nil {
+			err = errors.New("merge patch cannot replace the document with an array, use json-patch")
+		}
+		if err != nil {
+			return &Error{Kind: UsageError, Err: err}
+		}
+	}
+	if r != nil {
+		patch = r.redactPatch(patch)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(patch); err != nil {
+		return &Error{Kind: OutputError, Err: err}
+	}
+	return nil
+}
+
+func printResult(res *simplejson.Json, pretty bool, theme *Theme) error {
+	if !pretty || theme.Mode == ColorModeNone {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res.Interface()); err != nil {
+			return err
+		}
+		_, err := fmt.Println()
+		return err
+	}
+	binJSON, err := res.Encode()
+	if err != nil {
+		return err
+	}
+	fmtr := jsoncolor.NewFormatter()
+	fmtr.Indent = "  "
+	applyTheme(fmtr, theme.ANSI, theme)
+	if err := fmtr.Format(os.Stdout, binJSON); err != nil {
+		return err
+	}
+	_, err = fmt.Println()
+	return err
 }
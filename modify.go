@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/qoops-1/vuje/query"
+)
+
+// modification is a change of the document given on the command line with
+// -set as "query=value" or with -del as a query
+type modification struct {
+	del bool
+	arg string
+}
+
+func (m modification) String() string {
+	if m.del {
+		return "-del " + m.arg
+	}
+	return "-set " + m.arg
+}
+
+// modifications collects -set and -del flags in the order they are given
+type modifications struct {
+	list *[]modification
+	del  bool
+}
+
+func (m modifications) String() string {
+	return ""
+}
+
+func (m modifications) Set(arg string) error {
+	*m.list = append(*m.list, modification{del: m.del, arg: arg})
+	return nil
+}
+
+// parseAssignment splits "query=value" at the first "=" outside of quotes and brackets.
+// The value is JSON, or the name of a file with a JSON document after "@"
+func parseAssignment(arg string) (string, interface{}, error) {
+	i := assignmentIndex(arg)
+	if i < 0 {
+		return "", nil, usageErrorf("-set: expected query=value, got %q", arg)
+	}
+	q, text := arg[:i], arg[i+1:]
+	if strings.HasPrefix(text, "@") {
+		doc, err := readJSONFile(text[1:], "value")
+		if err != nil {
+			return "", nil, err
+		}
+		return q, doc.Interface(), nil
+	}
+	value, err := parseValue(text)
+	if err != nil {
+		return "", nil, usageErrorf("-set %s: %s", arg, err)
+	}
+	return q, value, nil
+}
+
+func assignmentIndex(arg string) int {
+	var quote rune
+	depth := 0
+	escaped := false
+	for i, ch := range arg {
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		case ch == '=' && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// expandPath lists the paths of the nodes the path selects. Wildcards and multi-selects
+// are expanded, keys and indices are kept also if the node does not exist
+func expandPath(node interface{}, path, prefix []query.Token) ([][]query.Token, error) {
+	if len(path) == 0 {
+		return [][]query.Token{prefix}, nil
+	}
+	var paths [][]query.Token
+	expand := func(child interface{}, tok query.Token) error {
+		rest, err := expandPath(child, path[1:], appendToken(prefix, tok))
+		paths = append(paths, rest...)
+		return err
+	}
+	var err error
+	switch t := path[0].(type) {
+	case query.Key:
+		obj, _ := node.(map[string]interface{})
+		err = expand(obj[string(t)], t)
+	case query.Index:
+		var child interface{}
+		if arr, ok := node.([]interface{}); ok && int(t) < len(arr) {
+			child = arr[t]
+		}
+		err = expand(child, t)
+	case query.Wildcard:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not an array", pointerOf(prefix), query.TypeName(node))
+		}
+		for i, el := range arr {
+			if err = expand(el, query.Index(i)); err != nil {
+				break
+			}
+		}
+	case query.MultiSelect:
+		obj, _ := node.(map[string]interface{})
+		for _, k := range t {
+			if err = expand(obj[k], query.Key(k)); err != nil {
+				break
+			}
+		}
+	default:
+		return nil, usageErrorf("%s cannot be modified", query.Format(path[:1], '.'))
+	}
+	return paths, err
+}
+
+// setAt sets the value at the path creating the objects on the way that do not exist
+func setAt(node interface{}, path []query.Token, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	switch t := path[0].(type) {
+	case query.Key:
+		obj, ok := node.(map[string]interface{})
+		if node == nil {
+			obj, ok = map[string]interface{}{}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s has no key %q", query.TypeName(node), string(t))
+		}
+		child, err := setAt(obj[string(t)], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		obj[string(t)] = child
+		return obj, nil
+	case query.Index:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s has no index %d", query.TypeName(node), t)
+		}
+		if int(t) > len(arr) {
+			return nil, fmt.Errorf("index %d is out of range, the array has %s", t, plural(len(arr), "element"))
+		}
+		if int(t) == len(arr) {
+			arr = append(arr, nil)
+		}
+		child, err := setAt(arr[t], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		arr[t] = child
+		return arr, nil
+	}
+	return nil, usageErrorf("%s cannot be modified", query.Format(path[:1], '.'))
+}
+
+// modify applies the modifications to the document one by one. Deleting a node that does
+// not exist is a NotFoundError, changes the document cannot take, e.g. a key of a number,
+// are a PatchError
+func modify(doc interface{}, mods []modification, sep rune) (interface{}, error) {
+	for _, m := range mods {
+		fail := func(err error) (interface{}, error) {
+			return nil, &Error{Kind: modifyErrorKind(err), Err: errors.Wrap(err, m.String())}
+		}
+		q, value := m.arg, interface{}(nil)
+		if !m.del {
+			var err error
+			if q, value, err = parseAssignment(m.arg); err != nil {
+				return nil, err
+			}
+		}
+		path, err := compileQuery(q, sep, doc)
+		if err != nil {
+			e := classifyQueryError(err)
+			e.Err = errors.Wrap(e.Err, m.String())
+			return nil, e
+		}
+		if len(path) > 0 && path[0] == query.Token(query.Key("")) {
+			path = path[1:]
+		}
+		paths, err := expandPath(doc, path, []query.Token{})
+		if err != nil {
+			return fail(err)
+		}
+		if !m.del {
+			for _, p := range paths {
+				if doc, err = setAt(doc, p, deepCopy(value)); err != nil {
+					return fail(errors.Wrap(err, pointerOf(p)))
+				}
+			}
+			continue
+		}
+		// the last elements of arrays go first, so that the indices of the others stay valid
+		for i := len(paths) - 1; i >= 0; i-- {
+			if len(paths[i]) == 0 {
+				return fail(errors.New("the root cannot be deleted"))
+			}
+			ptr, _ := query.ToPointer(paths[i])
+			if doc, _, err = pointerRemove(doc, ptr); err != nil {
+				return fail(errors.Wrap(err, ptr))
+			}
+		}
+	}
+	return doc, nil
+}
+
+// modifyErrorKind classifies the error of a modification by the first error in its chain
+// that tells the kind
+func modifyErrorKind(err error) ErrorKind {
+	for err != nil {
+		switch t := err.(type) {
+		case missingError:
+			return NotFoundError
+		case *Error:
+			return t.Kind
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return PatchError
+}
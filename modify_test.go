@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAssignment(t *testing.T) {
+	q, v, err := parseAssignment(`["a=b"].c\=d={"x": "=1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `["a=b"].c\=d`, q)
+	assert.Equal(t, `{"x":"=1"}`, compactJSON(v))
+
+	_, _, err = parseAssignment("a")
+	assert.EqualError(t, err, `-set: expected query=value, got "a"`)
+	_, _, err = parseAssignment("a=b")
+	assert.Equal(t, UsageError, err.(*Error).Kind)
+
+	dir, err := ioutil.TempDir("", "vuje")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "value.json")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(`[1, 2]`), 0644))
+	_, v, err = parseAssignment("a=@" + name)
+	assert.NoError(t, err)
+	assert.Equal(t, `[1,2]`, compactJSON(v))
+	_, _, err = parseAssignment("a=@" + name + ".missing")
+	assert.Equal(t, InputError, err.(*Error).Kind)
+}
+
+func TestModify(t *testing.T) {
+	doc := `{"spec": {"replicas": 1, "ports": [{"p": 80}, {"p": 443}]}, "meta": {"a": 1, "b": 2}}`
+	set := func(arg string) modification { return modification{arg: arg} }
+	del := func(arg string) modification { return modification{del: true, arg: arg} }
+	tbl := []struct {
+		mods []modification
+		res  string
+		err  string
+		kind ErrorKind
+	}{
+		{
+			mods: []modification{set("spec.replicas=3"), del("meta.a")},
+			res:  `{"meta":{"b":2},"spec":{"ports":[{"p":80},{"p":443}],"replicas":3}}`,
+		},
+		{
+			mods: []modification{set(`spec.ports[*].proto="tcp"`), set("new.obj={}"), set("spec.ports[2]=null")},
+			res:  `{"meta":{"a":1,"b":2},"new":{"obj":{}},"spec":{"ports":[{"p":80,"proto":"tcp"},{"p":443,"proto":"tcp"},null],"replicas":1}}`,
+		},
+		{
+			mods: []modification{del("spec.ports[*]"), del("meta.{a,b}"), set("/spec/replicas=0")},
+			res:  `{"meta":{},"spec":{"ports":[],"replicas":0}}`,
+		},
+		{mods: []modification{del("spec.zz")}, err: `-del spec.zz: /spec/zz: no key "zz"`, kind: NotFoundError},
+		{mods: []modification{del("spec.ports[5]")}, err: `-del spec.ports[5]: /spec/ports/5: index 5 is out of range, the array has 2 elements`, kind: NotFoundError},
+		// changes the document cannot take are told apart from missing nodes
+		{mods: []modification{set("spec.replicas.x=1")}, err: `-set spec.replicas.x=1: /spec/replicas/x: number has no key "x"`, kind: PatchError},
+		{mods: []modification{del("spec.replicas.x")}, err: `-del spec.replicas.x: /spec/replicas/x: number has no key "x"`, kind: PatchError},
+		{mods: []modification{set("meta[*]=1")}, err: `-set meta[*]=1: /meta is object, not an array`, kind: PatchError},
+		{mods: []modification{set("spec.ports[5]=1")}, err: `-set spec.ports[5]=1: /spec/ports/5: index 5 is out of range, the array has 2 elements`, kind: PatchError},
+		{mods: []modification{del("[*]")}, err: `-del [*]: (root) is object, not an array`, kind: PatchError},
+		{mods: []modification{del("/")}, kind: PatchError},
+		{mods: []modification{set("spec|keys=1")}, err: `-set spec|keys=1: |keys cannot be modified`, kind: UsageError},
+		{mods: []modification{set("a[=1")}, kind: UsageError},
+	}
+	for _, tt := range tbl {
+		res, err := modify(decode(t, doc), tt.mods, '.')
+		if tt.kind != "" {
+			assert.Equal(t, string(tt.kind), string(err.(*Error).Kind), "%v", tt.mods)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			}
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.res, compactJSON(res))
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	runewidth "github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+// wheelStep is the number of lines scrolled by one turn of the mouse wheel
+const wheelStep = 3
+
+func (e *Explorer) mouseInput(ev termbox.Event) {
+	if e.help != nil {
+		switch ev.Key {
+		case termbox.MouseWheelUp:
+			e.help.view.MoveWindow(-wheelStep, e.layout.ContentHeight)
+		case termbox.MouseWheelDown:
+			e.help.view.MoveWindow(wheelStep, e.layout.ContentHeight)
+		}
+		e.drawContents(true)
+		return
+	}
+	switch ev.Key {
+	case termbox.MouseWheelUp:
+		e.display.MoveWindow(-wheelStep, e.layout.ContentHeight)
+		e.drawContents(true)
+	case termbox.MouseWheelDown:
+		e.display.MoveWindow(wheelStep, e.layout.ContentHeight)
+		e.drawContents(true)
+	case termbox.MouseLeft:
+		if e.prompt == nil {
+			e.click(ev.MouseX, ev.MouseY)
+		}
+	}
+}
+
+func (e *Explorer) click(x, y int) {
+	l := e.layout
+	switch {
+	case y == l.PromptY:
+		pos := x - utf8.RuneCountInString(prompt)
+		if pos < 0 || pos > utf8.RuneCountInString(e.query.Raw()) {
+			return
+		}
+		e.query.QueryPos = pos
+		e.drawQueryLine()
+	case y >= l.CompletionY && y < l.CompletionY+l.CompletionHeight:
+		if !e.completionsShown() {
+			return
+		}
+		i := completionAt(e.completions, e.display.ActiveCompletion, l, x, y)
+		if i == -1 {
+			return
+		}
+		e.query.CompleteWith(e.completions[i])
+		e.display.ActiveCompletion = -1
+		e.syncWithQuery()
+	case y >= l.ContentY && y < l.ContentY+l.ContentHeight:
+		if e.display.Doc == nil || e.other != nil || constructed(e.docPath) {
+			return
+		}
+		var paths [][]query.Token
+		if e.display.Shape {
+			paths = e.shape().paths
+		} else if e.display.OnlyKeys {
+			paths = keyPaths(e.display.Doc.Interface())
+		} else {
+			paths = linePaths(e.display.Doc.Interface())
+		}
+		line := e.display.DocOffsetY + y - l.ContentY
+		if line >= len(paths) || paths[line] == nil {
+			return
+		}
+		path := append(append([]query.Token{}, e.docPath...), paths[line]...)
+		e.query.SetRaw(e.query.Format(path))
+		e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+		e.display.ActiveCompletion = -1
+		e.syncWithQuery()
+	}
+}
+
+// completionAt returns the index of the completion drawn at (x, y) or -1
+func completionAt(compls []string, active int, l Layout, x, y int) int {
+	lines := wrapCompletions(compls, l.Width)
+	n := visibleLines(lines, active, l.CompletionHeight) + y - l.CompletionY
+	if n < 0 || n >= len(lines) {
+		return -1
+	}
+	start := 0
+	for _, i := range lines[n] {
+		end := start + runewidth.StringWidth(compls[i])
+		if x >= start && x < end {
+			return i
+		}
+		start = end + 1
+	}
+	return -1
+}
+
+// linePaths returns the path relative to the document of the element starting at
+// every line of the pretty printed document. Closing brackets point to their container
+func linePaths(doc interface{}) [][]query.Token {
+	paths := [][]query.Token{{}}
+	var walk func(v interface{}, path []query.Token)
+	walk = func(v interface{}, path []query.Token) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if len(t) == 0 {
+				return
+			}
+			var keys []string
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := append(append([]query.Token{}, path...), query.Key(k))
+				paths = append(paths, p)
+				walk(t[k], p)
+			}
+			paths = append(paths, path)
+		case []interface{}:
+			if len(t) == 0 {
+				return
+			}
+			for i, el := range t {
+				p := append(append([]query.Token{}, path...), query.Index(i))
+				paths = append(paths, p)
+				walk(el, p)
+			}
+			paths = append(paths, path)
+		}
+	}
+	walk(doc, []query.Token{})
+	return paths
+}
+
+// keyPaths returns the paths of the lines displayed in keys-only mode
+func keyPaths(doc interface{}) [][]query.Token {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var paths [][]query.Token
+	for _, k := range keys {
+		paths = append(paths, []query.Token{query.Key(k)})
+	}
+	return paths
+}
+
+// constructed reports whether the path builds a new value with projections,
+// multi-selects or functions, so lines of the result are not nodes of the document
+func constructed(path []query.Token) bool {
+	for _, tok := range path {
+		switch tok.(type) {
+		case query.Wildcard, query.MultiSelect, query.Call:
+			return true
+		}
+	}
+	return false
+}
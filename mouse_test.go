@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/qoops-1/vuje/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinePaths(t *testing.T) {
+	doc, err := simplejson.NewFromReader(strings.NewReader(`{"b": {}, "a": [1, {"c": null}], "d": []}`))
+	assert.NoError(t, err)
+	pretty, _ := doc.EncodePretty()
+	paths := linePaths(doc.Interface())
+	assert.Equal(t, bytes.Count(pretty, []byte("\n"))+1, len(paths))
+	assert.Equal(t, [][]query.Token{
+		{},
+		{query.Key("a")},
+		{query.Key("a"), query.Index(0)},
+		{query.Key("a"), query.Index(1)},
+		{query.Key("a"), query.Index(1), query.Key("c")},
+		{query.Key("a"), query.Index(1)},
+		{query.Key("a")},
+		{query.Key("b")},
+		{query.Key("d")},
+		{},
+	}, paths)
+}
+
+func TestCompletionAt(t *testing.T) {
+	compls := []string{"aaa", "bb", "cccc", "d"}
+	l := NewLayout(7, 24, 2, false)
+	assert.Equal(t, 0, completionAt(compls, 0, l, 0, 1))
+	assert.Equal(t, 0, completionAt(compls, 0, l, 2, 1))
+	assert.Equal(t, -1, completionAt(compls, 0, l, 3, 1))
+	assert.Equal(t, 1, completionAt(compls, 0, l, 4, 1))
+	assert.Equal(t, 3, completionAt(compls, 0, l, 5, 2))
+	assert.Equal(t, -1, completionAt(compls, 0, l, 0, 3))
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+// patchOp is an operation of an RFC 6902 JSON Patch
+type patchOp struct {
+	Op    string          `json:"op"`
+	From  string          `json:"from,omitempty"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (op patchOp) String() string {
+	if op.From != "" {
+		return fmt.Sprintf("%s %s -> %s", op.Op, op.From, op.Path)
+	}
+	return fmt.Sprintf("%s %s", op.Op, op.Path)
+}
+
+// jsonPatch returns the JSON Patch that turns the old value at the path into the new one.
+// Elements of arrays are matched as in diffValues, operations on the elements
+// use their indices at the moment the operation is applied
+func jsonPatch(old, new interface{}, path []query.Token, key string) []patchOp {
+	p := &patcher{differ: differ{key: key}, ops: []patchOp{}}
+	p.patch(old, new, path)
+	return p.ops
+}
+
+type patcher struct {
+	differ
+	ops []patchOp
+}
+
+func (p *patcher) op(op string, path []query.Token, value interface{}) {
+	ptr, _ := query.ToPointer(path)
+	o := patchOp{Op: op, Path: ptr}
+	if op != "remove" {
+		o.Value = json.RawMessage(compactJSON(value))
+	}
+	p.ops = append(p.ops, o)
+}
+
+func (p *patcher) patch(old, new interface{}, path []query.Token) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			p.objects(o, n, path)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			p.arrays(o, n, path)
+			return
+		}
+	}
+	if !query.Equal(old, new) || query.TypeName(old) != query.TypeName(new) {
+		p.op("replace", path, new)
+	}
+}
+
+func (p *patcher) objects(old, new map[string]interface{}, path []query.Token) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ov, inOld := old[k]
+		nv, inNew := new[k]
+		switch {
+		case !inNew:
+			p.op("remove", appendToken(path, query.Key(k)), nil)
+		case !inOld:
+			p.op("add", appendToken(path, query.Key(k)), nv)
+		default:
+			p.patch(ov, nv, appendToken(path, query.Key(k)))
+		}
+	}
+}
+
+// arrays removes the unmatched old elements, then puts the new elements in their
+// places one by one, adding the unmatched ones and moving the matched ones
+func (p *patcher) arrays(old, new []interface{}, path []query.Token) {
+	m := p.match(old, new)
+	for k := len(m.removed) - 1; k >= 0; k-- {
+		p.op("remove", appendToken(path, query.Index(m.removed[k])), nil)
+	}
+	// current are the old indices of the elements in the order they have after the operations
+	removed := map[int]bool{}
+	for _, i := range m.removed {
+		removed[i] = true
+	}
+	var current []int
+	for i := range old {
+		if !removed[i] {
+			current = append(current, i)
+		}
+	}
+	oldOf := map[int]int{}
+	for _, pr := range m.pairs {
+		oldOf[pr.new] = pr.old
+	}
+	for j := range new {
+		i, matched := oldOf[j]
+		if !matched {
+			p.op("add", appendToken(path, query.Index(j)), new[j])
+			current = insertInt(current, j, -1)
+			continue
+		}
+		pos := 0
+		for current[pos] != i {
+			pos++
+		}
+		if pos != j {
+			from, _ := query.ToPointer(appendToken(path, query.Index(pos)))
+			to, _ := query.ToPointer(appendToken(path, query.Index(j)))
+			p.ops = append(p.ops, patchOp{Op: "move", From: from, Path: to})
+			current = insertInt(append(current[:pos:pos], current[pos+1:]...), j, i)
+		}
+		p.patch(old[i], new[j], appendToken(path, query.Index(j)))
+	}
+}
+
+func insertInt(ints []int, at, v int) []int {
+	ints = append(ints, 0)
+	copy(ints[at+1:], ints[at:])
+	ints[at] = v
+	return ints
+}
+
+// mergePatch returns the RFC 7396 merge patch that turns the old value into the new one.
+// Merge patches cannot set values to null, as null removes the key
+func mergePatch(old, new interface{}, path []query.Token) (interface{}, error) {
+	o, oldIsObj := old.(map[string]interface{})
+	n, newIsObj := new.(map[string]interface{})
+	if !oldIsObj || !newIsObj {
+		return new, checkNoNulls(new, path)
+	}
+	patch := map[string]interface{}{}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, nv := range n {
+		ov, ok := o[k]
+		if ok && query.Equal(ov, nv) && query.TypeName(ov) == query.TypeName(nv) {
+			continue
+		}
+		if nv == nil {
+			return nil, fmt.Errorf("merge patch cannot set %s to null, use json-patch", pointerOf(appendToken(path, query.Key(k))))
+		}
+		if !ok {
+			ov = nil
+		}
+		sub, err := mergePatch(ov, nv, appendToken(path, query.Key(k)))
+		if err != nil {
+			return nil, err
+		}
+		patch[k] = sub
+	}
+	return patch, nil
+}
+
+// checkNoNulls reports nulls in the objects of the value, they would remove keys when merged
+func checkNoNulls(v interface{}, path []query.Token) error {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for k, fv := range obj {
+		if fv == nil {
+			return fmt.Errorf("merge patch cannot set %s to null, use json-patch", pointerOf(appendToken(path, query.Key(k))))
+		}
+		if err := checkNoNulls(fv, appendToken(path, query.Key(k))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestMergePatch puts the merge patch of the node at the path into the objects above it
+func nestMergePatch(patch interface{}, path []query.Token) (interface{}, error) {
+	for i := len(path) - 1; i >= 0; i-- {
+		k, ok := path[i].(query.Key)
+		if !ok {
+			return nil, fmt.Errorf("merge patch cannot change elements of arrays, %s is one, use json-patch", pointerOf(path[:i+1]))
+		}
+		patch = map[string]interface{}{string(k): patch}
+	}
+	return patch, nil
+}
+
+// applyMergePatch merges the RFC 7396 patch into a copy of the document
+func applyMergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	target, ok := shallowCopy(doc).(map[string]interface{})
+	if !ok {
+		target = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(target, k)
+		} else {
+			target[k] = applyMergePatch(target[k], v)
+		}
+	}
+	return target
+}
+
+// PatchOpError is an operation of a JSON Patch that cannot be applied
+type PatchOpError struct {
+	// Index is the position of the operation in the patch, starting with 0
+	Index int
+	Op    string
+	Msg   string
+}
+
+func (e *PatchOpError) Error() string {
+	return fmt.Sprintf("operation %d (%s): %s", e.Index, e.Op, e.Msg)
+}
+
+// appendIndex stands for the "-" of pointers, the position after the last element
+const appendIndex = query.Index(-1)
+
+// applyJSONPatch applies the RFC 6902 patch to the document. The operations are applied
+// one by one, the first one that fails stops the patch. The document is not changed, the
+// containers on the way to the changed values are copied, so a failed patch changes nothing
+func applyJSONPatch(doc interface{}, patch []interface{}) (interface{}, error) {
+	for i, raw := range patch {
+		fail := func(op, format string, args ...interface{}) (interface{}, error) {
+			return nil, &PatchOpError{Index: i, Op: op, Msg: fmt.Sprintf(format, args...)}
+		}
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return fail(compactJSON(raw), "operation must be an object")
+		}
+		name, _ := obj["op"].(string)
+		ptr, ok := obj["path"].(string)
+		if !ok {
+			return fail(name, `missing "path"`)
+		}
+		desc := name + " " + ptr
+		from, hasFrom := obj["from"].(string)
+		if hasFrom {
+			desc = fmt.Sprintf("%s %s -> %s", name, from, ptr)
+		}
+		value, hasValue := obj["value"]
+		var err error
+		switch name {
+		case "add", "replace", "test":
+			if !hasValue {
+				return fail(desc, `missing "value"`)
+			}
+		case "move", "copy":
+			if !hasFrom {
+				return fail(desc, `missing "from"`)
+			}
+			if name == "move" && strings.HasPrefix(ptr+"/", from+"/") && ptr != from {
+				return fail(desc, "cannot move a value into itself")
+			}
+			if value, err = pointerGet(doc, from); err != nil {
+				return fail(desc, "from: %s", err)
+			}
+			if name == "copy" {
+				value = deepCopy(value)
+			}
+		case "remove":
+		default:
+			return fail(desc, "unknown operation %q", name)
+		}
+		switch name {
+		case "add", "copy":
+			doc, err = pointerAdd(doc, ptr, value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, ptr)
+		case "replace":
+			if doc, _, err = pointerRemove(doc, ptr); err == nil {
+				doc, err = pointerAdd(doc, ptr, value)
+			}
+		case "move":
+			if doc, _, err = pointerRemove(doc, from); err == nil {
+				doc, err = pointerAdd(doc, ptr, value)
+			}
+		case "test":
+			var actual interface{}
+			if actual, err = pointerGet(doc, ptr); err == nil && !query.Equal(actual, value) {
+				err = fmt.Errorf("value is %s, not %s", encodeValue(actual), encodeValue(value))
+			}
+		}
+		if err != nil {
+			return fail(desc, "%s", err)
+		}
+	}
+	return doc, nil
+}
+
+// resolvePointer parses the pointer into the path to its parent and the last step of it
+func resolvePointer(doc interface{}, ptr string) ([]query.Token, query.Token, error) {
+	parentPtr, last := ptr, ""
+	if i := strings.LastIndex(ptr, "/"); i >= 0 {
+		parentPtr, last = ptr[:i], ptr[i+1:]
+	}
+	if last == "-" {
+		parent, err := query.FromPointer(parentPtr, doc)
+		return parent, appendIndex, err
+	}
+	path, err := query.FromPointer(ptr, doc)
+	if err != nil || len(path) == 0 {
+		return nil, nil, err
+	}
+	return path[:len(path)-1], path[len(path)-1], nil
+}
+
+func pointerGet(doc interface{}, ptr string) (interface{}, error) {
+	path, err := query.FromPointer(ptr, doc)
+	if err != nil {
+		return nil, err
+	}
+	node := doc
+	for _, tok := range path {
+		if node, err = child(node, tok); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// missingError is a key or an element the document does not have, as opposed to
+// a step that cannot be taken at all, such as a key of a number
+type missingError string
+
+func (e missingError) Error() string {
+	return string(e)
+}
+
+func child(node interface{}, tok query.Token) (interface{}, error) {
+	switch t := node.(type) {
+	case map[string]interface{}:
+		if k, ok := tok.(query.Key); ok {
+			if v, ok := t[string(k)]; ok {
+				return v, nil
+			}
+			return nil, missingError(fmt.Sprintf("no key %q", string(k)))
+		}
+	case []interface{}:
+		if i, ok := tok.(query.Index); ok && i >= 0 {
+			if int(i) < len(t) {
+				return t[i], nil
+			}
+			return nil, missingError(fmt.Sprintf("index %d is out of range, the array has %s", i, plural(len(t), "element")))
+		}
+	}
+	return nil, fmt.Errorf("%s has no %s", query.TypeName(node), describeStep(tok))
+}
+
+func describeStep(tok query.Token) string {
+	if tok == query.Token(appendIndex) {
+		return `element "-"`
+	}
+	if i, ok := tok.(query.Index); ok {
+		return fmt.Sprintf("index %d", i)
+	}
+	return fmt.Sprintf("key %q", string(tok.(query.Key)))
+}
+
+// updateAt replaces the node at the path with the result of the function in a copy
+// of the containers on the way to it
+func updateAt(node interface{}, path []query.Token, f func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(path) == 0 {
+		return f(node)
+	}
+	c, err := child(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	updated, err := updateAt(c, path[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	switch t := shallowCopy(node).(type) {
+	case map[string]interface{}:
+		t[string(path[0].(query.Key))] = updated
+		return t, nil
+	case []interface{}:
+		t[path[0].(query.Index)] = updated
+		return t, nil
+	}
+	return node, nil
+}
+
+// pointerAdd adds the value to the object or inserts it into the array the pointer points into
+func pointerAdd(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+	if ptr == "" {
+		return value, nil
+	}
+	parent, last, err := resolvePointer(doc, ptr)
+	if err != nil {
+		return nil, err
+	}
+	return updateAt(doc, parent, func(node interface{}) (interface{}, error) {
+		switch t := node.(type) {
+		case map[string]interface{}:
+			if k, ok := last.(query.Key); ok {
+				obj := shallowCopy(t).(map[string]interface{})
+				obj[string(k)] = value
+				return obj, nil
+			}
+		case []interface{}:
+			i, ok := last.(query.Index)
+			if i == appendIndex {
+				i = query.Index(len(t))
+			}
+			if ok && i >= 0 && int(i) <= len(t) {
+				arr := make([]interface{}, 0, len(t)+1)
+				arr = append(append(append(arr, t[:i]...), value), t[i:]...)
+				return arr, nil
+			}
+			if ok {
+				return nil, fmt.Errorf("index %d is out of range, the array has %s", i, plural(len(t), "element"))
+			}
+		}
+		return nil, fmt.Errorf("cannot add %s to %s", describeStep(last), query.TypeName(node))
+	})
+}
+
+// pointerRemove removes the value the pointer points to and returns it
+func pointerRemove(doc interface{}, ptr string) (interface{}, interface{}, error) {
+	if ptr == "" {
+		return nil, doc, nil
+	}
+	parent, last, err := resolvePointer(doc, ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	var removed interface{}
+	doc, err = updateAt(doc, parent, func(node interface{}) (interface{}, error) {
+		if removed, err = child(node, last); err != nil {
+			return nil, err
+		}
+		switch t := shallowCopy(node).(type) {
+		case map[string]interface{}:
+			delete(t, string(last.(query.Key)))
+			return t, nil
+		case []interface{}:
+			i := last.(query.Index)
+			return append(t[:i], t[i+1:]...), nil
+		}
+		return node, nil
+	})
+	return doc, removed, err
+}
+
+// shallowCopy copies the object or the array, the values in it are shared
+func shallowCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, fv := range t {
+			c[k] = fv
+		}
+		return c
+	case []interface{}:
+		return append([]interface{}{}, t...)
+	}
+	return v
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, fv := range t {
+			c[k] = deepCopy(fv)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, el := range t {
+			c[i] = deepCopy(el)
+		}
+		return c
+	}
+	return v
+}
+
+// applyPatch applies a JSON Patch, an array of operations, or a merge patch, any other value
+func applyPatch(doc, patch interface{}) (interface{}, error) {
+	if ops, ok := patch.([]interface{}); ok {
+		return applyJSONPatch(doc, ops)
+	}
+	return applyMergePatch(doc, patch), nil
+}
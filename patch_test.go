@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+func encodeJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestJSONPatch(t *testing.T) {
+	tbl := []struct {
+		old, new, key string
+		res           string
+	}{
+		{old: `{"a": 1, "b": [1, 2]}`, new: `{"b": [1, 2], "a": 1.0}`, res: `[]`},
+		{old: `1`, new: `"1"`, res: `[{"op":"replace","path":"","value":"1"}]`},
+		{
+			old: `{"a": 1, "b": null, "c/d": {"e": true}}`,
+			new: `{"a": null, "c/d": {"e": false}, "f": [null]}`,
+			res: `[{"op":"replace","path":"/a","value":null},{"op":"remove","path":"/b"},` +
+				`{"op":"replace","path":"/c~1d/e","value":false},{"op":"add","path":"/f","value":[null]}]`,
+		},
+		{
+			old: `[1, 2, 3, 4]`,
+			new: `[0, 1, 3, 4, 5]`,
+			res: `[{"op":"remove","path":"/1"},{"op":"add","path":"/0","value":0},{"op":"add","path":"/4","value":5}]`,
+		},
+		{
+			old: `[{"id": 1, "n": "a"}, {"id": 2}, {"id": 3}]`,
+			new: `[{"id": 3}, {"id": 2}, {"id": 1, "n": "b"}]`,
+			key: "id",
+			res: `[{"op":"move","from":"/2","path":"/0"},{"op":"move","from":"/2","path":"/1"},` +
+				`{"op":"replace","path":"/2/n","value":"b"}]`,
+		},
+	}
+	for _, tt := range tbl {
+		old, new := decode(t, tt.old), decode(t, tt.new)
+		patch := jsonPatch(old, new, []query.Token{}, tt.key)
+		assert.Equal(t, tt.res, encodeJSON(t, patch), tt.old)
+
+		// the patch turns the old document into the new one
+		var ops []interface{}
+		assert.NoError(t, json.Unmarshal([]byte(encodeJSON(t, patch)), &ops))
+		res, err := applyJSONPatch(decode(t, tt.old), ops)
+		assert.NoError(t, err, tt.old)
+		assert.True(t, query.Equal(new, res), tt.old)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := `{"users": [{"id": 1}, {"id": 2}], "n": 0}`
+	tbl := []struct {
+		patch string
+		res   string
+		err   string
+	}{
+		{
+			patch: `[
+				{"op": "add", "path": "/users/-", "value": {"id": 3}},
+				{"op": "copy", "from": "/users/0", "path": "/first"},
+				{"op": "replace", "path": "/users/0/id", "value": 10},
+				{"op": "move", "from": "/n", "path": "/users/1/n"},
+				{"op": "remove", "path": "/users/2"},
+				{"op": "test", "path": "/first", "value": {"id": 1.0}}
+			]`,
+			res: `{"first":{"id":1},"users":[{"id":10},{"id":2,"n":0}]}`,
+		},
+		{patch: `[{"op": "replace", "path": "", "value": [1]}]`, res: `[1]`},
+		{patch: `[{"op": "remove", "path": "/users/5"}]`, err: "operation 0 (remove /users/5): index 5 is out of range, the array has 2 elements"},
+		{patch: `[{"op": "add", "path": "/users/3", "value": 1}]`, err: "operation 0 (add /users/3): index 3 is out of range, the array has 2 elements"},
+		{patch: `[{"op": "add", "path": "/n/a", "value": 1}]`, err: `operation 0 (add /n/a): cannot add key "a" to number`},
+		{patch: `[{"op": "test", "path": "/n", "value": 1}, {}]`, err: "operation 0 (test /n): value is 0, not 1"},
+		{patch: `[{"op": "move", "from": "/users", "path": "/users/0"}]`, err: "operation 0 (move /users -> /users/0): cannot move a value into itself"},
+		{patch: `[{"op": "copy", "from": "/x", "path": "/y"}]`, err: `operation 0 (copy /x -> /y): from: no key "x"`},
+		{patch: `[{"op": "test", "path": "/n", "value": 0}, {"op": "add", "path": "/a"}]`, err: `operation 1 (add /a): missing "value"`},
+		{patch: `[{"op": "inc", "path": "/n"}]`, err: `operation 0 (inc /n): unknown operation "inc"`},
+		{patch: `[{"op": "remove", "path": "/users/x"}]`, err: `operation 0 (remove /users/x): bad query "/users/x" at position 7: expected array index, got "x"`},
+	}
+	for _, tt := range tbl {
+		res, err := applyPatch(decode(t, doc), decode(t, tt.patch))
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.patch)
+			continue
+		}
+		assert.NoError(t, err, tt.patch)
+		assert.Equal(t, tt.res, encodeJSON(t, res), tt.patch)
+	}
+}
+
+func TestApplyJSONPatch_atomic(t *testing.T) {
+	// a failed patch leaves the document as it was, even after operations that succeeded
+	doc := decode(t, `{"users": [{"id": 1}, {"id": 2}], "n": 0}`)
+	users := doc.(map[string]interface{})["users"].([]interface{})
+	_, err := applyPatch(doc, decode(t, `[
+		{"op": "replace", "path": "/users/0/id", "value": 10},
+		{"op": "add", "path": "/users/1", "value": {"id": 3}},
+		{"op": "remove", "path": "/n"},
+		{"op": "test", "path": "/users/0/id", "value": 1}
+	]`))
+	assert.EqualError(t, err, "operation 3 (test /users/0/id): value is 10, not 1")
+	assert.Equal(t, `{"n":0,"users":[{"id":1},{"id":2}]}`, encodeJSON(t, doc))
+
+	// an element inserted into an array with spare capacity does not shift the array of the document
+	doc.(map[string]interface{})["users"] = append(make([]interface{}, 0, 4), users...)
+	users = doc.(map[string]interface{})["users"].([]interface{})
+	res, err := applyPatch(doc, decode(t, `[{"op": "add", "path": "/users/1", "value": {"id": 3}}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"n":0,"users":[{"id":1},{"id":3},{"id":2}]}`, encodeJSON(t, res))
+	assert.Equal(t, `[{"id":1},{"id":2},null,null]`, encodeJSON(t, users[:cap(users)]))
+
+	res = applyMergePatch(doc, decode(t, `{"n": null}`))
+	assert.Equal(t, `{"users":[{"id":1},{"id":2}]}`, encodeJSON(t, res))
+	assert.Contains(t, doc, "n")
+}
+
+func TestMergePatch(t *testing.T) {
+	old := decode(t, `{"a": 1, "b": {"c": [1], "d": "x"}, "e": true}`)
+	new := decode(t, `{"a": 1, "b": {"c": [2], "f": {"g": 1}}, "h": "y"}`)
+	patch, err := mergePatch(old, new, []query.Token{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"c":[2],"d":null,"f":{"g":1}},"e":null,"h":"y"}`, encodeJSON(t, patch))
+	res, err := applyPatch(old, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, encodeJSON(t, new), encodeJSON(t, res))
+
+	_, err = mergePatch(old, decode(t, `{"a": 1, "b": {"c": [1], "d": null}}`), []query.Token{})
+	assert.EqualError(t, err, "merge patch cannot set /b/d to null, use json-patch")
+	_, err = mergePatch(old, decode(t, `{"i": {"j": null}}`), []query.Token{})
+	assert.EqualError(t, err, "merge patch cannot set /i/j to null, use json-patch")
+
+	nested, err := nestMergePatch(map[string]interface{}{"c": nil}, []query.Token{query.Key("b")})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"c":null}}`, encodeJSON(t, nested))
+	_, err = nestMergePatch(1, []query.Token{query.Key("b"), query.Index(0)})
+	assert.EqualError(t, err, "merge patch cannot change elements of arrays, /b/0 is one, use json-patch")
+}
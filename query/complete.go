@@ -0,0 +1,181 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var indexStart = regexp.MustCompile(`\d+`)
+
+var argStart = regexp.MustCompile(`^\s*(\w+)\s*\(\s*([^(),]*)$`)
+
+// callArg returns the function of the unfinished call and the prefix of its argument
+// being typed. The function is nil unless the call is missing only the closing parenthesis
+func callArg(c ErrCall) (*Func, string) {
+	match := argStart.FindStringSubmatch(string(c))
+	if match == nil {
+		return nil, ""
+	}
+	f := LookupFunc(match[1])
+	if f == nil || len(f.Args) == 0 {
+		return nil, ""
+	}
+	return f, match[2]
+}
+
+// Completions lists the possible completions of the last token of the path. The node
+// is the one returned by Eval for the path. Nil is returned if there are none
+func Completions(node interface{}, path []Token) []string {
+	var keyword Token = Key("")
+	if len(path) > 0 {
+		keyword = path[len(path)-1]
+	}
+	keys := nodeKeys(node, projections(path))
+	switch t := keyword.(type) {
+	case ErrQuotedKey:
+		return quotedCompletions(keys, t)
+	case ErrIndex:
+		digits := indexStart.FindString(string(t))
+		return []string{fmt.Sprintf("[%s]", digits)}
+	case ErrMultiSelect:
+		picked, prefix := t.Keys()
+		for _, k := range picked {
+			delete(keys, k)
+		}
+		return matchKeys(keys, prefix)
+	case Key:
+		return matchKeys(keys, string(t))
+	case Call:
+		if t.Args != nil {
+			return nil
+		}
+		var names []string
+		for _, f := range funcs {
+			if strings.HasPrefix(f.Name, t.Name) {
+				names = append(names, f.Name)
+			}
+		}
+		return names
+	case ErrCall:
+		// arguments of functions are keys of the elements of the array
+		if f, prefix := callArg(t); f != nil {
+			return matchKeys(nodeKeys(node, 1), prefix)
+		}
+	}
+	return nil
+}
+
+// projections counts the array wildcards the node returned by Eval is nested in
+func projections(path []Token) int {
+	n := 0
+	for _, tok := range path {
+		if tok == Token(ArrayAsterisk) {
+			n++
+		}
+	}
+	return n
+}
+
+// nodeKeys returns the keys of the object node. For projections the keys
+// of all the objects in the projected arrays are merged
+func nodeKeys(node interface{}, depth int) map[string]bool {
+	keys := map[string]bool{}
+	if depth > 0 {
+		arr, _ := node.([]interface{})
+		for _, el := range arr {
+			for k := range nodeKeys(el, depth-1) {
+				keys[k] = true
+			}
+		}
+		return keys
+	}
+	obj, _ := node.(map[string]interface{})
+	for k := range obj {
+		keys[k] = true
+	}
+	return keys
+}
+
+func matchKeys(keys map[string]bool, prefix string) []string {
+	var matches []string
+	for jsonKey := range keys {
+		if strings.HasPrefix(jsonKey, prefix) {
+			matches = append(matches, jsonKey)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// quotedCompletions returns quoted keys starting with the unfinished quoted key
+func quotedCompletions(keys map[string]bool, quoted ErrQuotedKey) []string {
+	q := []rune(string(quoted))
+	prefix, size := unquote(q)
+	if size != -1 {
+		return nil
+	}
+	var matches []string
+	for _, k := range matchKeys(keys, prefix) {
+		matches = append(matches, Quote(k, q[1]))
+	}
+	return matches
+}
+
+// BestCompletion returns the suffix that completes the last token with the first completion
+func BestCompletion(last Token, compls []string) string {
+	if len(compls) == 0 {
+		return ""
+	}
+	switch t := last.(type) {
+	case Key:
+		return strings.TrimPrefix(compls[0], string(t))
+	case ErrIndex:
+		return strings.TrimPrefix(compls[0], string(t))
+	case ErrQuotedKey:
+		return strings.TrimPrefix(compls[0], string(t))
+	case ErrMultiSelect:
+		_, prefix := t.Keys()
+		return strings.TrimPrefix(compls[0], prefix)
+	case Call:
+		return strings.TrimPrefix(compls[0], t.Name)
+	case ErrCall:
+		_, prefix := callArg(t)
+		return strings.TrimPrefix(compls[0], prefix)
+	}
+	return ""
+}
+
+// Complete replaces the last token of the raw query with the completion and returns the
+// new query. Keys containing special characters are completed in the quoted form, e.g. ["a.b"]
+func Complete(rawQuery string, sep rune, compl string) string {
+	tokens, offsets, _ := ParseOffsets(rawQuery, sep)
+	last := len(tokens) - 1
+	switch t := tokens[last].(type) {
+	case Key:
+		if !NeedsQuoting(compl, sep) {
+			return rawQuery + strings.TrimPrefix(compl, string(t))
+		}
+		// the separator before the key is replaced as well
+		raw := []rune(rawQuery)
+		start := offsets[last]
+		if start > 0 && raw[start-1] == sep {
+			start--
+		}
+		return string(raw[:start]) + Quote(compl, '"')
+	case ErrIndex:
+		return rawQuery + strings.TrimPrefix(compl, string(t))
+	case ErrQuotedKey:
+		return rawQuery + strings.TrimPrefix(compl, string(t))
+	case ErrMultiSelect:
+		_, prefix := t.Keys()
+		return rawQuery + escapeChars(strings.TrimPrefix(compl, prefix), ",} ")
+	case Call:
+		return rawQuery + strings.TrimPrefix(compl, t.Name)
+	case ErrCall:
+		_, prefix := callArg(t)
+		return rawQuery + strings.TrimPrefix(compl, prefix)
+	}
+	return rawQuery
+}
@@ -0,0 +1,359 @@
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Decoder turns an encoded value, e.g. a base64 string or a Unix timestamp, into a readable one.
+// Decoders are applied with "|" like functions, arrays are decoded element by element
+type Decoder struct {
+	Name string
+	Desc string
+	// Decode returns an error if the value is not encoded the way the decoder expects
+	Decode func(v interface{}) (interface{}, error)
+	// Annotate describes the value shortly if it looks encoded, it is nil for decoders
+	// that are only applied explicitly
+	Annotate func(v interface{}) (string, bool)
+}
+
+var decoders []*Decoder
+
+func init() {
+	registerDecoder(&Decoder{Name: "jwt", Desc: "header and payload of a JSON Web Token", Decode: decodeJWT, Annotate: annotateJWT})
+	registerDecoder(&Decoder{Name: "utc", Desc: "Unix or ISO 8601 timestamp as UTC time", Decode: utcTime, Annotate: annotateTime})
+	registerDecoder(&Decoder{Name: "local", Desc: "Unix or ISO 8601 timestamp as local time", Decode: localTime})
+	registerDecoder(&Decoder{Name: "base64", Desc: "text or hex dump of base64 data", Decode: decodeBase64, Annotate: annotateBase64})
+	registerDecoder(&Decoder{Name: "url_query", Desc: "URL query string as an object", Decode: decodeURLQuery, Annotate: annotateURLQuery})
+}
+
+// registerDecoder makes the decoder available as a function of queries and adds
+// its annotations to the ones returned by Annotate. Decoders are tried in the order
+// they are registered. It is called from init only, names must not be taken by functions
+func registerDecoder(d *Decoder) {
+	if LookupFunc(d.Name) != nil {
+		panic(fmt.Sprintf("decoder %q is named like an existing function", d.Name))
+	}
+	decoders = append(decoders, d)
+	funcs = append(funcs, &Func{Name: d.Name, Desc: d.Desc, apply: func(node interface{}, _ []string) (interface{}, error) {
+		arr, ok := node.([]interface{})
+		if !ok {
+			return d.Decode(node)
+		}
+		decoded := make([]interface{}, len(arr))
+		for i, el := range arr {
+			v, err := d.Decode(el)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %s", i, err)
+			}
+			decoded[i] = v
+		}
+		return decoded, nil
+	}})
+}
+
+// Decoders returns the registered decoders
+func Decoders() []*Decoder {
+	return decoders
+}
+
+// maxAnnotation is the length of annotations in runes, longer ones are cut
+const maxAnnotation = 80
+
+// Annotate returns the annotation of the first decoder that recognizes the value
+func Annotate(v interface{}) (string, bool) {
+	for _, d := range decoders {
+		if d.Annotate == nil {
+			continue
+		}
+		if note, ok := d.Annotate(v); ok {
+			if runes := []rune(note); len(runes) > maxAnnotation {
+				note = string(runes[:maxAnnotation-1]) + "…"
+			}
+			return note, true
+		}
+	}
+	return "", false
+}
+
+func compact(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+var base64Encodings = []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding}
+
+// base64Bytes decodes standard or URL-safe base64 with or without padding
+func base64Bytes(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, typeError(v)
+	}
+	s = strings.TrimSpace(s)
+	for _, enc := range base64Encodings {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, errors.New("not base64")
+}
+
+// text returns the data as a string if it is printable UTF-8 text
+func text(data []byte) (string, bool) {
+	if !utf8.Valid(data) {
+		return "", false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return "", false
+		}
+	}
+	return string(data), true
+}
+
+// decodeBase64 returns the text, or the lines of the hex dump of binary data
+func decodeBase64(v interface{}) (interface{}, error) {
+	data, err := base64Bytes(v)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := text(data); ok {
+		return s, nil
+	}
+	lines := []interface{}{}
+	for _, line := range strings.Split(strings.TrimSuffix(hex.Dump(data), "\n"), "\n") {
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+var base64Regex = regexp.MustCompile(`^[A-Za-z0-9+/_-]{8,}={0,2}$`)
+
+// annotateBase64 shows the text of base64 strings, binary data is not annotated
+// because most identifiers and hashes would look like it
+func annotateBase64(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || !base64Regex.MatchString(s) {
+		return "", false
+	}
+	data, err := base64Bytes(s)
+	if err != nil {
+		return "", false
+	}
+	t, ok := text(data)
+	if !ok || strings.TrimSpace(t) == "" {
+		return "", false
+	}
+	return "base64: " + compact(t), true
+}
+
+// jsonSegment decodes a base64 encoded JSON object, a part of a JWT
+func jsonSegment(seg string) (interface{}, error) {
+	data, err := base64Bytes(seg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, errors.New("not a JSON object")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("not a JSON object")
+	}
+	return obj, nil
+}
+
+// decodeJWT returns the header and the payload of the token, the signature is not verified
+func decodeJWT(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, typeError(v)
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT, expected three parts separated by dots")
+	}
+	header, err := jsonSegment(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("header: %s", err)
+	}
+	payload, err := jsonSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("payload: %s", err)
+	}
+	return map[string]interface{}{"header": header, "payload": payload, "signature": parts[2]}, nil
+}
+
+func annotateJWT(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || !strings.HasPrefix(strings.TrimPrefix(s, "Bearer "), "eyJ") {
+		return "", false
+	}
+	token, err := decodeJWT(s)
+	if err != nil {
+		return "", false
+	}
+	t := token.(map[string]interface{})
+	return "jwt: " + compact(t["header"]) + " " + compact(t["payload"]), true
+}
+
+// decodeURLQuery returns the parameters of the query string, or of the query of the URL, as
+// an object. Repeated parameters are arrays of their values
+func decodeURLQuery(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, typeError(v)
+	}
+	if i := strings.Index(s, "?"); i >= 0 {
+		s = s[i+1:]
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[:i]
+	}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("not a URL query: %s", err)
+	}
+	obj := map[string]interface{}{}
+	for k, vs := range values {
+		if len(vs) == 1 {
+			obj[k] = vs[0]
+			continue
+		}
+		arr := make([]interface{}, len(vs))
+		for i, v := range vs {
+			arr[i] = v
+		}
+		obj[k] = arr
+	}
+	return obj, nil
+}
+
+var urlQueryRegex = regexp.MustCompile(`^([^?\s]*\?)?[^=&?#\s]+=[^&#\s]*(&[^=&#\s]+(=[^&#\s]*)?)*(#\S*)?$`)
+
+func annotateURLQuery(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok || !urlQueryRegex.MatchString(s) {
+		return "", false
+	}
+	obj, err := decodeURLQuery(s)
+	if err != nil {
+		return "", false
+	}
+	return "url_query: " + compact(obj), true
+}
+
+// timeLayouts are the ISO 8601 forms of timestamps, times without a zone are in UTC
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// timeFormat is how decoded timestamps are shown
+const timeFormat = "2006-01-02 15:04:05.999999999 MST"
+
+// timestamp converts a Unix timestamp or an ISO 8601 string to time. The unit of Unix
+// timestamps is guessed from their size: seconds, milliseconds, microseconds or nanoseconds
+func timestamp(v interface{}) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, errors.New("not an ISO 8601 timestamp")
+	}
+	r, ok := ToRat(v)
+	if !ok {
+		return time.Time{}, typeError(v)
+	}
+	abs := new(big.Rat).Abs(r)
+	perUnit := int64(1)
+	switch {
+	case abs.Cmp(big.NewRat(1e11, 1)) < 0:
+		perUnit = 1e9
+	case abs.Cmp(big.NewRat(1e14, 1)) < 0:
+		perUnit = 1e6
+	case abs.Cmp(big.NewRat(1e17, 1)) < 0:
+		perUnit = 1e3
+	}
+	ns := new(big.Rat).Mul(r, big.NewRat(perUnit, 1))
+	n := new(big.Int).Quo(ns.Num(), ns.Denom())
+	if !n.IsInt64() {
+		return time.Time{}, errors.New("timestamp out of range")
+	}
+	return time.Unix(0, n.Int64()), nil
+}
+
+func utcTime(v interface{}) (interface{}, error) {
+	t, err := timestamp(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.UTC().Format(timeFormat), nil
+}
+
+func localTime(v interface{}) (interface{}, error) {
+	t, err := timestamp(v)
+	if err != nil {
+		return nil, err
+	}
+	return t.Local().Format(timeFormat), nil
+}
+
+// annotatedTimes are the Unix timestamps in seconds that are annotated, from 2000 to 2100.
+// Numbers outside of the range are more likely to be counts or sizes
+var annotatedTimes = [2]int64{946684800, 4102444800}
+
+// annotateTime shows Unix timestamps as UTC and local time, and ISO 8601 timestamps
+// in local time when it differs from the zone they are written in
+func annotateTime(v interface{}) (string, bool) {
+	if s, ok := v.(string); ok {
+		t, err := timestamp(s)
+		if err != nil {
+			return "", false
+		}
+		_, offset := t.Zone()
+		if _, local := t.Local().Zone(); local == offset {
+			return "", false
+		}
+		return "local: " + t.Local().Format(timeFormat), true
+	}
+	r, ok := ToRat(v)
+	if !ok || !r.IsInt() {
+		return "", false
+	}
+	t, err := timestamp(v)
+	if err != nil {
+		return "", false
+	}
+	sec := t.Unix()
+	if sec < annotatedTimes[0] || sec >= annotatedTimes[1] {
+		return "", false
+	}
+	note := t.UTC().Format(timeFormat)
+	if _, offset := t.Local().Zone(); offset != 0 {
+		note += ", " + t.Local().Format(timeFormat)
+	}
+	return note, true
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval_decoders(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("CET", 3600)
+	defer func() { time.Local = local }()
+
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(`{
+		"text": "aGVsbG8gd29ybGQ=",
+		"bin": "3q2+7w",
+		"token": "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln",
+		"qs": "https://x.org/p?a=1&b=2&b=3#top",
+		"times": [1700000000, 1700000000123, "2023-11-14T23:13:20+01:00", 1.5],
+		"n": 3
+	}`))
+	d.UseNumber()
+	assert.NoError(t, d.Decode(&doc))
+
+	tbl := []struct {
+		query string
+		res   interface{}
+		err   string
+	}{
+		{query: "text|base64", res: "hello world"},
+		{query: "bin|base64", res: []interface{}{"00000000  de ad be ef                                       |....|"}},
+		{query: "token|jwt", res: map[string]interface{}{
+			"header":    map[string]interface{}{"alg": "HS256"},
+			"payload":   map[string]interface{}{"sub": "1"},
+			"signature": "c2ln",
+		}},
+		{query: "qs|url_query", res: map[string]interface{}{"a": "1", "b": []interface{}{"2", "3"}}},
+		{query: "times|utc", res: []interface{}{
+			"2023-11-14 22:13:20 UTC", "2023-11-14 22:13:20.123 UTC", "2023-11-14 22:13:20 UTC", "1970-01-01 00:00:01.5 UTC",
+		}},
+		{query: "times[0]|local", res: "2023-11-14 23:13:20 CET"},
+		{query: "text|jwt", err: "jwt: not a JWT, expected three parts separated by dots"},
+		{query: "n|base64", err: "base64: cannot be applied to number"},
+		{query: "times|base64", err: "base64: element 0: cannot be applied to number"},
+	}
+	for _, tt := range tbl {
+		toks, err := Compile(tt.query, '.')
+		assert.NoError(t, err, tt.query)
+		res, err := Get(doc, toks)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.query)
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.res, res, tt.query)
+	}
+
+	// a decoder cannot shadow a function
+	assert.PanicsWithValue(t, `decoder "keys" is named like an existing function`, func() {
+		registerDecoder(&Decoder{Name: "keys", Decode: decodeBase64})
+	})
+}
+
+func TestAnnotate(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("CET", 3600)
+	defer func() { time.Local = local }()
+
+	tbl := []struct {
+		value interface{}
+		note  string
+	}{
+		{value: json.Number("1700000000"), note: "2023-11-14 22:13:20 UTC, 2023-11-14 23:13:20 CET"},
+		{value: "2023-11-14T22:13:20Z", note: "local: 2023-11-14 23:13:20 CET"},
+		{value: "aGVsbG8gd29ybGQ=", note: `base64: "hello world"`},
+		{value: "Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln", note: `jwt: {"alg":"HS256"} {"sub":"1"}`},
+		{value: "?a=1&b", note: `url_query: {"a":"1","b":""}`},
+		{value: json.Number("8080")},
+		{value: json.Number("1700000000.5")},
+		{value: "username"},
+		{value: "deadbeefdeadbeef"},
+		{value: "a = b"},
+		{value: true},
+	}
+	for _, tt := range tbl {
+		note, ok := Annotate(tt.value)
+		assert.Equal(t, tt.note != "", ok, tt.value)
+		assert.Equal(t, tt.note, note, tt.value)
+	}
+}
@@ -0,0 +1,181 @@
+package query
+
+import (
+	"fmt"
+)
+
+// Match tells how much of the query matched the document
+type Match int
+
+const (
+	// NoMatch means that the query does not select anything
+	NoMatch Match = iota
+	// Partial means that all the tokens but the last one matched. The last one
+	// is the incomplete key or index being typed
+	Partial
+	// Full means that the whole query matched
+	Full
+)
+
+// NotFoundError is returned when a step of the path does not exist in the document
+type NotFoundError struct {
+	Path []Token
+	// Step is the index of the first token of Path that did not match
+	Step int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("path not found: no %s in %s", tokenString(e.Path[e.Step]), pathString(e.Path[:e.Step]))
+}
+
+// Eval evaluates the path against a document decoded by encoding/json. On a Partial match
+// the returned node is the one the last token should be completed in.
+//
+// The array wildcard "[*]" projects the rest of the path onto every element of the array.
+// The result of the projection is the array of the elements the rest of the path matched.
+// Function calls at the end of the path are applied to the result of the whole path
+func Eval(doc interface{}, path []Token) (interface{}, Match) {
+	node, match, _ := evalQuery(doc, path)
+	return node, match
+}
+
+func evalQuery(doc interface{}, path []Token) (interface{}, Match, error) {
+	if len(path) > 1 && path[0] == Token(Key("")) {
+		// queries to the root array and to quoted keys start with an empty key, e.g. "[0]"
+		obj, _ := doc.(map[string]interface{})
+		if _, ok := obj[""]; !ok {
+			path = path[1:]
+		}
+	}
+	calls := len(path)
+	for i, tok := range path {
+		switch tok.(type) {
+		case Call, ErrCall:
+			calls = i
+		}
+		if calls != len(path) {
+			break
+		}
+	}
+	node, match := eval(doc, path[:calls])
+	if calls == len(path) || match != Full {
+		if calls != len(path) {
+			match = NoMatch
+		}
+		return node, match, nil
+	}
+	return applyCalls(node, path[calls:])
+}
+
+func eval(node interface{}, path []Token) (interface{}, Match) {
+	for step, tok := range path {
+		switch t := tok.(type) {
+		case Index:
+			arr, ok := node.([]interface{})
+			if !ok || int(t) < 0 || int(t) >= len(arr) {
+				return nil, NoMatch
+			}
+			node = arr[t]
+		case Key:
+			obj, ok := node.(map[string]interface{})
+			var child interface{}
+			if ok {
+				child, ok = obj[string(t)]
+			}
+			if !ok {
+				if step == len(path)-1 {
+					return node, Partial
+				}
+				return nil, NoMatch
+			}
+			node = child
+		case Wildcard:
+			arr, ok := node.([]interface{})
+			if !ok {
+				return nil, NoMatch
+			}
+			return project(arr, path[step+1:])
+		case MultiSelect:
+			obj, ok := node.(map[string]interface{})
+			if !ok {
+				return nil, NoMatch
+			}
+			selected := make(map[string]interface{}, len(t))
+			for _, k := range t {
+				selected[k] = obj[k]
+			}
+			node = selected
+		case ErrMultiSelect:
+			if _, ok := node.(map[string]interface{}); !ok {
+				return nil, NoMatch
+			}
+			return node, Partial
+		case ErrIndex, ErrQuotedKey:
+			return node, Partial
+		case ErrKey:
+			return nil, NoMatch
+		}
+	}
+	return node, Full
+}
+
+// project evaluates the path against every element of the array. The projection
+// matches fully if the path matched at least one element fully or the array is empty.
+// Otherwise the nodes of the elements that matched partially are returned
+func project(arr []interface{}, path []Token) (interface{}, Match) {
+	full := []interface{}{}
+	var partial []interface{}
+	for _, el := range arr {
+		switch node, match := eval(el, path); match {
+		case Full:
+			full = append(full, node)
+		case Partial:
+			partial = append(partial, node)
+		}
+	}
+	if len(full) > 0 || len(arr) == 0 {
+		return full, Full
+	}
+	if len(partial) > 0 {
+		return partial, Partial
+	}
+	return nil, NoMatch
+}
+
+// Get evaluates the path against the document and returns a *NotFoundError
+// unless the whole path matches or a *FuncError if a function cannot be applied
+func Get(doc interface{}, path []Token) (interface{}, error) {
+	node, match, err := evalQuery(doc, path)
+	if match == Full {
+		return node, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for step := range path {
+		if _, m := Eval(doc, path[:step+1]); m != Full {
+			return nil, &NotFoundError{Path: path, Step: step}
+		}
+	}
+	return nil, &NotFoundError{Path: path, Step: len(path) - 1}
+}
+
+func tokenString(tok Token) string {
+	switch t := tok.(type) {
+	case Key:
+		return fmt.Sprintf("key %q", string(t))
+	case Index:
+		return fmt.Sprintf("index [%d]", int(t))
+	case Call:
+		return fmt.Sprintf("function %q", t.Name)
+	default:
+		return fmt.Sprintf("%q", t)
+	}
+}
+
+func pathString(path []Token) string {
+	if len(path) == 0 || (len(path) == 1 && path[0] == Token(Key(""))) {
+		return "the root"
+	}
+	return fmt.Sprintf("%q", Format(path, '.'))
+}
@@ -0,0 +1,27 @@
+package query_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+func Example() {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"users": [{"name": "ann", "email": "ann@example.com"}]}`), &doc)
+
+	path, err := query.Compile("users[0].name", '.')
+	if err != nil {
+		panic(err)
+	}
+	name, err := query.Get(doc, path)
+	fmt.Println(name, err)
+
+	partial, _ := query.Parse("users[0].e", '.')
+	node, _ := query.Eval(doc, partial)
+	fmt.Println(query.Completions(node, partial))
+	// Output:
+	// ann <nil>
+	// [email]
+}
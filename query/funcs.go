@@ -0,0 +1,501 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Func is a built-in function that can be applied to the result of a query with "|"
+type Func struct {
+	Name string
+	// Args are the names of the arguments of the function
+	Args  []string
+	Desc  string
+	apply func(node interface{}, args []string) (interface{}, error)
+}
+
+// Usage returns the function with its arguments as it is written in queries, e.g. group_by(key)
+func (f *Func) Usage() string {
+	return Format([]Token{Call{Name: f.Name, Args: f.Args}}, '.')[1:]
+}
+
+// FuncError is returned when a function cannot be applied to the result of the query
+type FuncError struct {
+	Func string
+	Msg  string
+}
+
+func (e *FuncError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Func, e.Msg)
+}
+
+var funcs = []*Func{
+	{Name: "length", Desc: "number of elements, keys or characters", apply: length},
+	{Name: "keys", Desc: "sorted keys of an object or indices of an array", apply: keys},
+	{Name: "values", Desc: "values of an object in the order of keys", apply: values},
+	{Name: "type", Desc: "type of the value", apply: typeOf},
+	{Name: "sort", Desc: "array sorted by value", apply: sortArray},
+	{Name: "unique", Desc: "sorted array without duplicates", apply: unique},
+	{Name: "first", Desc: "first element of an array", apply: first},
+	{Name: "last", Desc: "last element of an array", apply: last},
+	{Name: "sum", Desc: "sum of the numbers of an array", apply: sum},
+	{Name: "avg", Desc: "average of the numbers of an array", apply: avg},
+	{Name: "min", Desc: "smallest element of an array", apply: minOf},
+	{Name: "max", Desc: "largest element of an array", apply: maxOf},
+	{Name: "count_by", Args: []string{"key"}, Desc: "number of elements for every value of the key", apply: countBy},
+	{Name: "group_by", Args: []string{"key"}, Desc: "elements grouped by the value of the key", apply: groupBy},
+}
+
+// Funcs returns all the built-in functions
+func Funcs() []*Func {
+	return funcs
+}
+
+// LookupFunc returns the built-in function with the name or nil if there is none
+func LookupFunc(name string) *Func {
+	for _, f := range funcs {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// checkCall validates the name of the function and the number of its arguments
+func checkCall(c Call) error {
+	f := LookupFunc(c.Name)
+	if f == nil {
+		return fmt.Errorf("unknown function %q", c.Name)
+	}
+	if len(c.Args) != len(f.Args) {
+		switch len(f.Args) {
+		case 0:
+			return fmt.Errorf("%s takes no arguments", f.Name)
+		case 1:
+			return fmt.Errorf("%s expects 1 argument", f.Name)
+		default:
+			return fmt.Errorf("%s expects %d arguments", f.Name, len(f.Args))
+		}
+	}
+	return nil
+}
+
+// applyCalls pipes the node through the functions. An unfinished or unknown
+// function name at the end of the query matches partially
+func applyCalls(node interface{}, calls []Token) (interface{}, Match, error) {
+	for i, tok := range calls {
+		c, ok := tok.(Call)
+		if !ok {
+			// ErrCall is the last token of the query
+			return node, Partial, nil
+		}
+		if err := checkCall(c); err != nil {
+			if i == len(calls)-1 && c.Args == nil {
+				return node, Partial, nil
+			}
+			return nil, NoMatch, &FuncError{Func: c.Name, Msg: err.Error()}
+		}
+		var err error
+		node, err = LookupFunc(c.Name).apply(node, c.Args)
+		if err != nil {
+			return nil, NoMatch, &FuncError{Func: c.Name, Msg: err.Error()}
+		}
+	}
+	return node, Full, nil
+}
+
+// TypeName returns the JSON type of a value decoded by encoding/json
+func TypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := ToRat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func typeError(v interface{}) error {
+	return fmt.Errorf("cannot be applied to %s", TypeName(v))
+}
+
+func length(node interface{}, _ []string) (interface{}, error) {
+	switch t := node.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return utf8.RuneCountInString(t), nil
+	case []interface{}:
+		return len(t), nil
+	case map[string]interface{}:
+		return len(t), nil
+	}
+	return nil, typeError(node)
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	var keys []string
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func keys(node interface{}, _ []string) (interface{}, error) {
+	res := []interface{}{}
+	switch t := node.(type) {
+	case []interface{}:
+		for i := range t {
+			res = append(res, i)
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			res = append(res, k)
+		}
+	default:
+		return nil, typeError(node)
+	}
+	return res, nil
+}
+
+func values(node interface{}, _ []string) (interface{}, error) {
+	switch t := node.(type) {
+	case []interface{}:
+		return t, nil
+	case map[string]interface{}:
+		res := []interface{}{}
+		for _, k := range sortedKeys(t) {
+			res = append(res, t[k])
+		}
+		return res, nil
+	}
+	return nil, typeError(node)
+}
+
+func typeOf(node interface{}, _ []string) (interface{}, error) {
+	return TypeName(node), nil
+}
+
+func sortArray(node interface{}, _ []string) (interface{}, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	sorted := append([]interface{}{}, arr...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], sorted[j]) < 0
+	})
+	return sorted, nil
+}
+
+func unique(node interface{}, args []string) (interface{}, error) {
+	sorted, err := sortArray(node, args)
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for i, v := range sorted.([]interface{}) {
+		if i == 0 || compare(res[len(res)-1], v) != 0 {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func first(node interface{}, _ []string) (interface{}, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	return arr[0], nil
+}
+
+func last(node interface{}, _ []string) (interface{}, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	return arr[len(arr)-1], nil
+}
+
+// numbers returns the numbers of the array as exact fractions. Nulls are skipped
+func numbers(node interface{}) ([]*big.Rat, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	var nums []*big.Rat
+	for i, v := range arr {
+		if v == nil {
+			continue
+		}
+		r, ok := ToRat(v)
+		if !ok {
+			return nil, fmt.Errorf("element [%d] is %s, not a number", i, TypeName(v))
+		}
+		nums = append(nums, r)
+	}
+	return nums, nil
+}
+
+func total(nums []*big.Rat) *big.Rat {
+	res := new(big.Rat)
+	for _, n := range nums {
+		res.Add(res, n)
+	}
+	return res
+}
+
+func sum(node interface{}, _ []string) (interface{}, error) {
+	nums, err := numbers(node)
+	if err != nil {
+		return nil, err
+	}
+	return fromRat(total(nums)), nil
+}
+
+func avg(node interface{}, _ []string) (interface{}, error) {
+	nums, err := numbers(node)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+	count := new(big.Rat).SetInt64(int64(len(nums)))
+	return fromRat(new(big.Rat).Quo(total(nums), count)), nil
+}
+
+// extreme returns the element of the array that wins the comparison with all the others.
+// Nulls are skipped like in sum, the result is null only if there is nothing else
+func extreme(node interface{}, wins func(c int) bool) (interface{}, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	var res interface{}
+	for _, v := range arr {
+		if v != nil && (res == nil || wins(compare(v, res))) {
+			res = v
+		}
+	}
+	return res, nil
+}
+
+func minOf(node interface{}, _ []string) (interface{}, error) {
+	return extreme(node, func(c int) bool { return c < 0 })
+}
+
+func maxOf(node interface{}, _ []string) (interface{}, error) {
+	return extreme(node, func(c int) bool { return c > 0 })
+}
+
+// groupKeys returns the object key of the group of every element of the array. Strings
+// are used as is, other values are encoded as JSON, e.g. 200 or null. Values of different
+// types that would share a group, e.g. 1 and "1", or null and a missing key, are an error
+func groupKeys(node interface{}, key string) ([]string, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, typeError(node)
+	}
+	keys := make([]string, len(arr))
+	kinds := map[string]string{}
+	for i, el := range arr {
+		obj, _ := el.(map[string]interface{})
+		v, found := obj[key]
+		kind := "missing"
+		if found {
+			kind = TypeName(v)
+		}
+		keys[i] = groupKey(v)
+		if prev, ok := kinds[keys[i]]; ok && prev != kind {
+			return nil, fmt.Errorf("%s and %s values of %q both make the group %q", prev, kind, key, keys[i])
+		}
+		kinds[keys[i]] = kind
+	}
+	return keys, nil
+}
+
+func groupKey(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
+
+func countBy(node interface{}, args []string) (interface{}, error) {
+	keys, err := groupKeys(node, args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+	for _, k := range keys {
+		n, _ := res[k].(int)
+		res[k] = n + 1
+	}
+	return res, nil
+}
+
+func groupBy(node interface{}, args []string) (interface{}, error) {
+	keys, err := groupKeys(node, args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+	for i, el := range node.([]interface{}) {
+		group, _ := res[keys[i]].([]interface{})
+		res[keys[i]] = append(group, el)
+	}
+	return res, nil
+}
+
+// typeOrder is the order of values of different types in sorted arrays
+var typeOrder = map[string]int{"null": 0, "boolean": 1, "number": 2, "string": 3, "array": 4, "object": 5}
+
+// compare orders JSON values: null < false < true < numbers < strings < arrays < objects.
+// Arrays are compared element by element, objects by their sorted keys and then values
+func compare(a, b interface{}) int {
+	ta, tb := TypeName(a), TypeName(b)
+	if ta != tb {
+		return typeOrder[ta] - typeOrder[tb]
+	}
+	switch ta {
+	case "boolean":
+		ab, bb := a.(bool), b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case bb:
+			return -1
+		}
+		return 1
+	case "number":
+		return compareNumbers(a, b)
+	case "string":
+		as, bs := a.(string), b.(string)
+		switch {
+		case as < bs:
+			return -1
+		case as > bs:
+			return 1
+		}
+		return 0
+	case "array":
+		aa, ba := a.([]interface{}), b.([]interface{})
+		for i := 0; i < len(aa) && i < len(ba); i++ {
+			if c := compare(aa[i], ba[i]); c != 0 {
+				return c
+			}
+		}
+		return len(aa) - len(ba)
+	case "object":
+		ao, bo := a.(map[string]interface{}), b.(map[string]interface{})
+		ak, bk := sortedKeys(ao), sortedKeys(bo)
+		if c := compare(stringsOf(ak), stringsOf(bk)); c != 0 {
+			return c
+		}
+		for _, k := range ak {
+			if c := compare(ao[k], bo[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func stringsOf(ss []string) []interface{} {
+	res := make([]interface{}, len(ss))
+	for i, s := range ss {
+		res[i] = s
+	}
+	return res
+}
+
+// compareNumbers compares numbers exactly, without rounding large integers to floats
+func compareNumbers(a, b interface{}) int {
+	ar, _ := ToRat(a)
+	br, _ := ToRat(b)
+	return ar.Cmp(br)
+}
+
+// ToRat converts the number decoded by encoding/json, with or without UseNumber. Floats
+// are converted as the shortest decimal that prints them, so 0.1 equals json.Number("0.1")
+func ToRat(v interface{}) (*big.Rat, bool) {
+	switch t := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(t)), true
+	case int64:
+		return new(big.Rat).SetInt64(t), true
+	case float64:
+		return new(big.Rat).SetString(strconv.FormatFloat(t, 'g', -1, 64))
+	case json.Number:
+		return new(big.Rat).SetString(string(t))
+	}
+	return nil, false
+}
+
+// Equal reports whether the decoded JSON values are equal. Numbers are equal if their
+// values are, e.g. 1, 1.0 and json.Number("1e0")
+func Equal(a, b interface{}) bool {
+	if ra, ok := ToRat(a); ok {
+		rb, ok := ToRat(b)
+		return ok && ra.Cmp(rb) == 0
+	}
+	switch ta := a.(type) {
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !Equal(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for k, va := range ta {
+			vb, ok := tb[k]
+			if !ok || !Equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// fromRat converts the number back to json.Number. Integers are printed in full,
+// other numbers as the shortest float that rounds to them
+func fromRat(r *big.Rat) json.Number {
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+	f, _ := r.Float64()
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
@@ -0,0 +1,171 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEval_funcs(t *testing.T) {
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(`{
+		"items": [{"tag": "b"}, {"tag": "a"}, {"tag": "b"}, {"id": 1}],
+		"user": {"name": "ann", "age": 30},
+		"mixed": [3, "x", null, [1], true, 1.5, false, {"a": 1}, 9007199254740993, 9007199254740992],
+		"s": "ключ"
+	}`))
+	d.UseNumber()
+	assert.NoError(t, d.Decode(&doc))
+
+	tbl := []struct {
+		query string
+		res   interface{}
+	}{
+		{query: "items|length", res: 4},
+		{query: "s|length", res: 4},
+		{query: "user|keys", res: []interface{}{"age", "name"}},
+		{query: "items|keys|last", res: 3},
+		{query: "user|values", res: []interface{}{json.Number("30"), "ann"}},
+		{query: "user.age|type", res: "number"},
+		{query: "|type", res: "object"},
+		{query: "items[*].tag|unique", res: []interface{}{"a", "b"}},
+		{query: "items[*].tag|sort|first", res: "a"},
+		{query: "user|keys|length", res: 2},
+		{
+			query: "mixed|sort",
+			res: []interface{}{
+				nil, false, true, json.Number("1.5"), json.Number("3"),
+				json.Number("9007199254740992"), json.Number("9007199254740993"),
+				"x", []interface{}{json.Number("1")}, map[string]interface{}{"a": json.Number("1")},
+			},
+		},
+	}
+	for _, tt := range tbl {
+		path, err := Compile(tt.query, '.')
+		assert.NoError(t, err, tt.query)
+		res, err := Get(doc, path)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.res, res, tt.query)
+	}
+
+	path, _ := Compile("user.age|keys", '.')
+	_, err := Get(doc, path)
+	assert.Equal(t, &FuncError{Func: "keys", Msg: "cannot be applied to number"}, err)
+	assert.EqualError(t, err, "keys: cannot be applied to number")
+	_, match := Eval(doc, path)
+	assert.Equal(t, NoMatch, match)
+
+	// the function name being typed matches partially
+	path, _ = Parse("user|ke", '.')
+	node, match := Eval(doc, path)
+	assert.Equal(t, Partial, match)
+	assert.Equal(t, doc.(map[string]interface{})["user"], node)
+
+	path, _ = Parse("user.x|keys", '.')
+	_, match = Eval(doc, path)
+	assert.Equal(t, NoMatch, match)
+}
+
+func TestEval_aggregations(t *testing.T) {
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(`{
+		"metrics": [{"latency": 9007199254740993}, {"latency": 1}, {"latency": null}, {}],
+		"floats": [0.1, 0.2, 1],
+		"events": [{"type": "a", "status": 200}, {"type": "b", "status": 500}, {"type": "a", "status": 200}, {"status": null}],
+		"mixed": [3, "x", null],
+		"groups": [{"t": 1}, {"t": "1"}],
+		"nulls": [{"t": null}, {"t": 2}, {}],
+		"empty": []
+	}`))
+	d.UseNumber()
+	assert.NoError(t, d.Decode(&doc))
+	events := doc.(map[string]interface{})["events"].([]interface{})
+
+	tbl := []struct {
+		query string
+		res   interface{}
+	}{
+		{query: "metrics[*].latency|sum", res: json.Number("9007199254740994")},
+		{query: "metrics[*].latency|avg", res: json.Number("4503599627370497")},
+		{query: "metrics[*].latency|max", res: json.Number("9007199254740993")},
+		{query: "metrics[*].latency|min", res: json.Number("1")},
+		{query: "floats|sum", res: json.Number("1.3")},
+		{query: "floats|avg", res: json.Number("0.43333333333333335")},
+		{query: "floats|min", res: json.Number("0.1")},
+		{query: "mixed|max", res: "x"},
+		{query: "mixed|min", res: json.Number("3")},
+		{query: "events[*].status|sum", res: json.Number("900")},
+		{query: "mixed|keys|sum", res: json.Number("3")},
+		{query: "empty|avg", res: nil},
+		{query: "empty|sum", res: json.Number("0")},
+		{query: "events|count_by(status)", res: map[string]interface{}{"200": 2, "500": 1, "null": 1}},
+		{
+			query: "events|group_by(type)",
+			res: map[string]interface{}{
+				"a":    []interface{}{events[0], events[2]},
+				"b":    []interface{}{events[1]},
+				"null": []interface{}{events[3]},
+			},
+		},
+		{query: "events|group_by(type)|keys", res: []interface{}{"a", "b", "null"}},
+	}
+	for _, tt := range tbl {
+		path, err := Compile(tt.query, '.')
+		assert.NoError(t, err, tt.query)
+		res, err := Get(doc, path)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.res, res, tt.query)
+	}
+
+	path, _ := Compile("mixed|sum", '.')
+	_, err := Get(doc, path)
+	assert.EqualError(t, err, "sum: element [1] is string, not a number")
+	// values of different types do not share a group
+	path, _ = Compile("groups|count_by(t)", '.')
+	_, err = Get(doc, path)
+	assert.EqualError(t, err, `count_by: number and string values of "t" both make the group "1"`)
+	path, _ = Compile("nulls|group_by(t)", '.')
+	_, err = Get(doc, path)
+	assert.EqualError(t, err, `group_by: null and missing values of "t" both make the group "null"`)
+	_, err = Compile("events|count_by", '.')
+	assert.EqualError(t, err, `bad query "events|count_by" at position 7: count_by expects 1 argument`)
+
+	// keys of the elements are completed inside the parentheses
+	path, _ = Parse("events|count_by(st", '.')
+	node, match := Eval(doc, path)
+	assert.Equal(t, Partial, match)
+	assert.Equal(t, []string{"status"}, Completions(node, path))
+	assert.Equal(t, "atus", BestCompletion(path[len(path)-1], []string{"status"}))
+	assert.Equal(t, "events|count_by(status", Complete("events|count_by(st", '.', "status"))
+	path, _ = Parse("events|sort(", '.')
+	assert.Nil(t, Completions(node, path))
+}
+
+func TestFunc_Usage(t *testing.T) {
+	assert.Equal(t, "length", LookupFunc("length").Usage())
+	assert.Equal(t, "g(a,b)", (&Func{Name: "g", Args: []string{"a", "b"}}).Usage())
+	assert.Equal(t, "group_by(key)", LookupFunc("group_by").Usage())
+	assert.Nil(t, LookupFunc("nope"))
+}
+
+func TestEqual(t *testing.T) {
+	tbl := []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{a: 1, b: json.Number("1.0"), equal: true},
+		{a: 0.1, b: json.Number("1e-1"), equal: true},
+		{a: json.Number("12345678901234567890"), b: json.Number("12345678901234567891"), equal: false},
+		{a: []interface{}{1, "a"}, b: []interface{}{json.Number("1"), "a"}, equal: true},
+		{a: map[string]interface{}{"a": nil}, b: map[string]interface{}{"b": nil}, equal: false},
+		{a: "1", b: 1, equal: false},
+		{a: nil, b: false, equal: false},
+	}
+	for _, tt := range tbl {
+		assert.Equal(t, tt.equal, Equal(tt.a, tt.b), "%v %v", tt.a, tt.b)
+		// sorting agrees with the equality
+		assert.Equal(t, tt.equal, compare(tt.a, tt.b) == 0, "%v %v", tt.a, tt.b)
+	}
+}
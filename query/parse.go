@@ -0,0 +1,414 @@
+// Package query implements the path syntax of vuje: parsing of queries,
+// their evaluation against decoded JSON documents and completion of partial queries.
+//
+// A query is a list of object keys separated by a separator rune (usually ".")
+// and array indices in square brackets, e.g. "items[2].name". Characters with
+// a special meaning are escaped with "\" or the whole key is quoted in brackets,
+// e.g. items[2]["file.name"]. The result of the path can be piped to built-in
+// functions, e.g. "items|length".
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Esc is the escape character of queries
+	Esc = '\\'
+	// Pipe separates the path from the functions applied to its result
+	Pipe          = '|'
+	Asterisk      = Wildcard("*")
+	ArrayAsterisk = Wildcard("[*]")
+)
+
+// Token is a single step of a parsed query. It is one of Key, Index, Wildcard,
+// MultiSelect or one of the Err tokens for the unparsable rest of the query
+type Token interface{}
+
+// Key selects a value of an object
+type Key string
+
+// ErrKey is the unparsable rest of the query following an index
+type ErrKey string
+
+// Index selects an element of an array
+type Index int
+
+// ErrIndex is the unparsable rest of the query starting with "["
+type ErrIndex string
+
+// ErrQuotedKey is the unparsable rest of the query starting with a quoted key
+// without the closing quote or bracket, e.g. ["a.b
+type ErrQuotedKey string
+
+// Wildcard selects all the elements of a node
+type Wildcard string
+
+// MultiSelect builds an object of the listed keys of a node, e.g. {id,name}.
+// Keys missing in the node are null in the new object
+type MultiSelect []string
+
+// Call applies a built-in function to the result of the query before it, e.g. |length
+type Call struct {
+	Name string
+	Args []string
+}
+
+// ErrCall is the unparsable rest of the query starting with a function call, e.g. |sort(
+type ErrCall string
+
+// ErrMultiSelect is the rest of the query starting with a multi-select without
+// the closing brace, e.g. {id,na
+type ErrMultiSelect string
+
+// Keys returns the complete keys of the unfinished multi-select and the prefix of the key being typed
+func (ms ErrMultiSelect) Keys() (picked []string, prefix string) {
+	keys, _ := splitMultiSelect([]rune(string(ms)))
+	return keys[:len(keys)-1], keys[len(keys)-1]
+}
+
+// SyntaxError describes a query that cannot be parsed. Pos is the offset in runes
+// of the first character that could not be parsed
+type SyntaxError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bad query %q at position %d: %s", e.Query, e.Pos, e.Msg)
+}
+
+var idxRegex = regexp.MustCompile(`^\[([1-9]\d*|0)\]`)
+
+// Parse splits the query into tokens. It never fails: the unparsable rest of the query is
+// returned as the last ErrKey, ErrIndex or ErrQuotedKey token, so that partially typed queries
+// can be completed. inEscape reports whether the query ends with an unfinished escape
+func Parse(rawQuery string, sep rune) (tokens []Token, inEscape bool) {
+	tokens, _, inEscape = ParseOffsets(rawQuery, sep)
+	return tokens, inEscape
+}
+
+// ParseOffsets is like Parse but also returns the offset in runes where every token starts
+// in the raw query. Offsets of keys following the separator point right after it
+func ParseOffsets(rawQuery string, sep rune) (tokens []Token, offsets []int, inEscape bool) {
+	query := []rune(rawQuery)
+	inEscape = false
+	var curToken Token = Key("")
+	curOffset := 0
+	// closed is set after brackets, nothing but a separator or brackets can follow them
+	closed := false
+	push := func(tok Token, offset int) {
+		tokens = append(tokens, tok)
+		offsets = append(offsets, offset)
+	}
+	for i := 0; i < len(query); i++ {
+		switch {
+		case inEscape:
+			inEscape = false
+			fallthrough
+		default:
+			curKey, ok := curToken.(Key)
+			if !ok || closed {
+				push(curToken, curOffset)
+				push(ErrKey(query[i:]), i)
+				return
+			}
+			curKey += Key(query[i])
+			curToken = curKey
+		case query[i] == Esc:
+			inEscape = true
+		case query[i] == Pipe && sep != Pipe:
+			push(curToken, curOffset)
+			for i < len(query) {
+				call, size := parseCall(query[i:])
+				push(call, i+1)
+				if size == -1 {
+					return
+				}
+				i += size
+			}
+			return
+		case query[i] == '{' && curToken == Token(Key("")) && !closed:
+			contents, size := parseMultiSelect(query[i:])
+			if size == -1 {
+				push(contents, i)
+				return
+			}
+			curToken = contents
+			curOffset = i
+			closed = true
+			i += size - 1
+		case query[i] == sep:
+			push(curToken, curOffset)
+			curToken = Key("")
+			curOffset = i + 1
+			closed = false
+		case query[i] == '[':
+			push(curToken, curOffset)
+			contents, size := parseBrackets(query[i:])
+			if size == -1 {
+				push(contents, i)
+				return
+			}
+			curToken = contents
+			curOffset = i
+			closed = true
+			i += size - 1
+		}
+	}
+	push(curToken, curOffset)
+	return
+}
+
+// Compile parses the query and returns a *SyntaxError if any part of it cannot be parsed
+// or it calls unknown functions or passes them wrong arguments
+func Compile(rawQuery string, sep rune) ([]Token, error) {
+	tokens, offsets, inEscape := ParseOffsets(rawQuery, sep)
+	queryLen := utf8.RuneCountInString(rawQuery)
+	if inEscape {
+		return nil, &SyntaxError{Query: rawQuery, Pos: queryLen - 1, Msg: "unfinished escape sequence"}
+	}
+	for i, tok := range tokens {
+		switch t := tok.(type) {
+		case Call:
+			if err := checkCall(t); err != nil {
+				return nil, &SyntaxError{Query: rawQuery, Pos: offsets[i], Msg: err.Error()}
+			}
+		case ErrCall:
+			return nil, &SyntaxError{Query: rawQuery, Pos: offsets[i], Msg: "expected function name and arguments in parentheses"}
+		case ErrIndex:
+			pos := queryLen - utf8.RuneCountInString(string(t))
+			return nil, &SyntaxError{Query: rawQuery, Pos: pos, Msg: "expected index in square brackets"}
+		case ErrQuotedKey:
+			pos := queryLen - utf8.RuneCountInString(string(t))
+			return nil, &SyntaxError{Query: rawQuery, Pos: pos, Msg: "unterminated quoted key"}
+		case ErrMultiSelect:
+			pos := queryLen - utf8.RuneCountInString(string(t))
+			return nil, &SyntaxError{Query: rawQuery, Pos: pos, Msg: "expected \"}\" at the end of multi-select"}
+		case ErrKey:
+			pos := queryLen - utf8.RuneCountInString(string(t))
+			return nil, &SyntaxError{Query: rawQuery, Pos: pos, Msg: fmt.Sprintf("expected %q or \"[\" after index", string(sep))}
+		}
+	}
+	return tokens, nil
+}
+
+// parseBrackets returns a parsed token and its length in runes. If length == -1, the token parsing failed
+func parseBrackets(q []rune) (Token, int) {
+	if strings.HasPrefix(string(q), string(ArrayAsterisk)) {
+		return ArrayAsterisk, len(string(ArrayAsterisk))
+	}
+	if len(q) > 1 && (q[1] == '"' || q[1] == '\'') {
+		return parseQuotedKey(q)
+	}
+	match := idxRegex.FindStringSubmatch(string(q))
+	if match == nil {
+		return ErrIndex(q), -1
+	}
+	idx, err := strconv.Atoi(match[1])
+	if err != nil {
+		// the number does not fit into int
+		return ErrIndex(q), -1
+	}
+	return Index(idx), len(match[0])
+}
+
+// parseQuotedKey parses a key in quotes inside brackets, e.g. ["a.b"] or ['a.b'].
+// Inside quotes only the quote and the escape character have to be escaped
+func parseQuotedKey(q []rune) (Token, int) {
+	key, size := unquote(q)
+	if size == -1 || size >= len(q) || q[size] != ']' {
+		return ErrQuotedKey(q), -1
+	}
+	return Key(key), size + 1
+}
+
+// unquote reads the quoted string following the opening bracket. It returns
+// the unescaped string and the length of the brackets up to the closing quote
+// or -1 if the closing quote is missing
+func unquote(q []rune) (string, int) {
+	quote := q[1]
+	var key []rune
+	for i := 2; i < len(q); i++ {
+		switch {
+		case q[i] == Esc:
+			if i+1 < len(q) {
+				key = append(key, q[i+1])
+			}
+			i++
+		case q[i] == quote:
+			return string(key), i + 1
+		default:
+			key = append(key, q[i])
+		}
+	}
+	return string(key), -1
+}
+
+var callRegex = regexp.MustCompile(`^\|\s*(\w*)\s*(?:\(([^()]*)\))?\s*(?:$|\|)`)
+
+// parseCall parses a function call following the pipe, e.g. |group_by(type).
+// It returns the call and its length in runes up to the next pipe. If length == -1,
+// the call cannot be parsed
+func parseCall(q []rune) (Token, int) {
+	match := callRegex.FindStringSubmatchIndex(string(q))
+	if match == nil {
+		return ErrCall(q[1:]), -1
+	}
+	raw := string(q)
+	call := Call{Name: raw[match[2]:match[3]]}
+	if match[4] != -1 {
+		call.Args = []string{}
+		for _, arg := range strings.Split(raw[match[4]:match[5]], ",") {
+			if arg = strings.TrimSpace(arg); arg != "" {
+				call.Args = append(call.Args, arg)
+			}
+		}
+	}
+	end := match[1]
+	if strings.HasSuffix(raw[:end], string(Pipe)) && end > 1 {
+		// the next call starts at the pipe
+		end--
+	}
+	return call, utf8.RuneCountInString(raw[:end])
+}
+
+// parseMultiSelect parses the list of keys in braces. If length == -1, the closing brace is missing
+func parseMultiSelect(q []rune) (Token, int) {
+	keys, size := splitMultiSelect(q)
+	if size == -1 {
+		return ErrMultiSelect(q), -1
+	}
+	var selected MultiSelect
+	for _, k := range keys {
+		if k != "" {
+			selected = append(selected, k)
+		}
+	}
+	return selected, size
+}
+
+// splitMultiSelect reads the comma separated keys following the opening brace.
+// It returns the keys and the length of the multi-select or -1 if there is no closing brace.
+// Spaces around keys are ignored unless they are escaped
+func splitMultiSelect(q []rune) ([]string, int) {
+	var keys []string
+	var key []rune
+	// escaped marks the runes of the key that were escaped, they are never trimmed
+	var escaped []bool
+	add := func(r rune, esc bool) {
+		key = append(key, r)
+		escaped = append(escaped, esc)
+	}
+	// trim drops the unescaped spaces at the start of the key, and at the end unless it is still typed
+	trim := func(end bool) string {
+		from, to := 0, len(key)
+		for from < to && key[from] == ' ' && !escaped[from] {
+			from++
+		}
+		for end && to > from && key[to-1] == ' ' && !escaped[to-1] {
+			to--
+		}
+		k := string(key[from:to])
+		key, escaped = nil, nil
+		return k
+	}
+	for i := 1; i < len(q); i++ {
+		switch q[i] {
+		case Esc:
+			if i+1 < len(q) {
+				add(q[i+1], true)
+			}
+			i++
+		case ',':
+			keys = append(keys, trim(true))
+		case '}':
+			return append(keys, trim(true)), i + 1
+		default:
+			add(q[i], false)
+		}
+	}
+	return append(keys, trim(false)), -1
+}
+
+// NeedsQuoting reports whether the key contains characters that have to be escaped in queries
+func NeedsQuoting(key string, sep rune) bool {
+	return strings.ContainsAny(key, string([]rune{Esc, sep, '[', Pipe})) || strings.HasPrefix(key, "{")
+}
+
+// escapeChars escapes every occurrence of the characters
+func escapeChars(s string, chars string) string {
+	var escaped []rune
+	for _, ch := range s {
+		if ch == Esc || strings.ContainsRune(chars, ch) {
+			escaped = append(escaped, Esc)
+		}
+		escaped = append(escaped, ch)
+	}
+	return string(escaped)
+}
+
+// Quote returns the key in quotes inside brackets, e.g. ["a.b"]. The quote is either a double or a single quote
+func Quote(key string, quote rune) string {
+	escape := string(Esc)
+	key = strings.Replace(key, escape, escape+escape, -1)
+	key = strings.Replace(key, string(quote), escape+string(quote), -1)
+	return "[" + string(quote) + key + string(quote) + "]"
+}
+
+// Escape escapes all the characters of the key that have a special meaning in queries
+func Escape(key string, sep rune) string {
+	escape := string(Esc)
+	unescaped := []string{escape, string(sep), "[", string(Pipe)}
+	for _, specialSymbol := range unescaped {
+		key = strings.Replace(key, specialSymbol, escape+specialSymbol, -1)
+	}
+	return key
+}
+
+// Format builds a query that selects the path. Keys with special characters are quoted
+func Format(path []Token, sep rune) string {
+	if len(path) > 0 && path[0] == Token(Key("")) {
+		// the root of queries starting with "[" or "."
+		path = path[1:]
+	}
+	var raw string
+	for i, tok := range path {
+		switch t := tok.(type) {
+		case Key:
+			// a query starting with "/" or "$." would be taken for a pointer or JSONPath
+			if NeedsQuoting(string(t), sep) || i == 0 && (IsPointer(string(t)) || IsJSONPath(string(t))) {
+				raw += Quote(string(t), '"')
+				continue
+			}
+			if i > 0 {
+				raw += string(sep)
+			}
+			raw += string(t)
+		case Index:
+			raw += "[" + strconv.Itoa(int(t)) + "]"
+		case Wildcard:
+			raw += string(t)
+		case MultiSelect:
+			if i > 0 {
+				raw += string(sep)
+			}
+			var keys []string
+			for _, k := range t {
+				keys = append(keys, escapeChars(k, ",} "))
+			}
+			raw += "{" + strings.Join(keys, ",") + "}"
+		case Call:
+			raw += string(Pipe) + t.Name
+			if t.Args != nil {
+				raw += "(" + strings.Join(t.Args, ",") + ")"
+			}
+		}
+	}
+	return raw
+}
@@ -0,0 +1,370 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	sep := '.'
+	tbl := []struct {
+		inQuery       string
+		outLastEscape bool
+		outTokens     []Token
+	}{
+		{
+			inQuery:       "",
+			outLastEscape: false,
+			outTokens:     []Token{Key("")},
+		},
+		{
+			inQuery:       "key",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key")},
+		},
+		{
+			inQuery:       "key1.key2",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key1"), Key("key2")},
+		},
+		{
+			inQuery:       "key[5].key2",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Index(5), Key("key2")},
+		},
+		{
+			inQuery:       "key[1][10]",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Index(1), Index(10)},
+		},
+		{
+			inQuery:       "key[1",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), ErrIndex("[1")},
+		},
+		{
+			inQuery:       "key.",
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Key("")},
+		},
+		{
+			inQuery:       `ke\.y`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("ke.y")},
+		},
+		{
+			inQuery:       `ke\.y.ke\.y2`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("ke.y"), Key("ke.y2")},
+		},
+		{
+			inQuery:       `ke[y1.key2`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("ke"), ErrIndex("[y1.key2")},
+		},
+		{
+			inQuery:       `key\`,
+			outLastEscape: true,
+			outTokens:     []Token{Key("key")},
+		},
+		{
+			inQuery:       `["a.b[c]"]`,
+			outLastEscape: false,
+			outTokens:     []Token{Key(""), Key("a.b[c]")},
+		},
+		{
+			inQuery:       `key['k"ey'].x["\"\\"][1]`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Key(`k"ey`), Key("x"), Key(`"\`), Index(1)},
+		},
+		{
+			inQuery:       `key["ключ"]`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Key("ключ")},
+		},
+		{
+			inQuery:       `key["a.b`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), ErrQuotedKey(`["a.b`)},
+		},
+		{
+			inQuery:       `key["a"x`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), ErrQuotedKey(`["a"x`)},
+		},
+		{
+			inQuery:       `key["a"]x`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("key"), Key("a"), ErrKey("x")},
+		},
+		{
+			inQuery:       "user.{id,name}",
+			outLastEscape: false,
+			outTokens:     []Token{Key("user"), MultiSelect{"id", "name"}},
+		},
+		{
+			inQuery:       `[*].{id, st\,atus,}`,
+			outLastEscape: false,
+			outTokens:     []Token{Key(""), ArrayAsterisk, MultiSelect{"id", "st,atus"}},
+		},
+		{
+			inQuery:       "user.{id,na",
+			outLastEscape: false,
+			outTokens:     []Token{Key("user"), ErrMultiSelect("{id,na")},
+		},
+		{
+			inQuery:       "us{er",
+			outLastEscape: false,
+			outTokens:     []Token{Key("us{er")},
+		},
+		{
+			inQuery:       "{id}x",
+			outLastEscape: false,
+			outTokens:     []Token{MultiSelect{"id"}, ErrKey("x")},
+		},
+		{
+			inQuery:       "items[*].tag|unique | sort",
+			outLastEscape: false,
+			outTokens:     []Token{Key("items"), ArrayAsterisk, Key("tag"), Call{Name: "unique"}, Call{Name: "sort"}},
+		},
+		{
+			inQuery:       "|le",
+			outLastEscape: false,
+			outTokens:     []Token{Key(""), Call{Name: "le"}},
+		},
+		{
+			inQuery:       "events|group_by( type )|",
+			outLastEscape: false,
+			outTokens:     []Token{Key("events"), Call{Name: "group_by", Args: []string{"type"}}, Call{Name: ""}},
+		},
+		{
+			inQuery:       "a|sort(",
+			outLastEscape: false,
+			outTokens:     []Token{Key("a"), ErrCall("sort(")},
+		},
+		{
+			inQuery:       `a\|b|length`,
+			outLastEscape: false,
+			outTokens:     []Token{Key("a|b"), Call{Name: "length"}},
+		},
+	}
+
+	for _, tt := range tbl {
+		tokens, lastEscape := Parse(tt.inQuery, sep)
+		assert.Equal(t, tt.outTokens, tokens)
+		assert.Equal(t, tt.outLastEscape, lastEscape)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tbl := []struct {
+		path []Token
+		raw  string
+	}{
+		{path: []Token{Key("key1"), Key("key2")}, raw: "key1.key2"},
+		{path: []Token{Key("a"), Key("b.c"), Key(`c["\`)}, raw: `a["b.c"]["c[\"\\"]`},
+		{path: []Token{Key("key"), Index(1), Index(10)}, raw: "key[1][10]"},
+		{path: []Token{Key("u"), MultiSelect{" a", "b ", "c d", "e,}"}}, raw: `u.{\ a,b\ ,c\ d,e\,\}}`},
+	}
+	for _, tt := range tbl {
+		raw := Format(tt.path, '.')
+		assert.Equal(t, tt.raw, raw)
+		tokens, _ := Parse(raw, '.')
+		assert.Equal(t, tt.path, tokens)
+	}
+	assert.Equal(t, "", Format([]Token{}, '.'))
+	assert.Equal(t, "[2].a", Format([]Token{Index(2), Key("a")}, '.'))
+	// the root of queries starting with brackets is dropped
+	assert.Equal(t, "a b.x", Format([]Token{Key(""), Key("a b"), Key("x")}, '.'))
+}
+
+func TestParseOffsets(t *testing.T) {
+	tokens, offsets, _ := ParseOffsets(`ab.c\.d[1]["x"].`, '.')
+	assert.Equal(t, []Token{Key("ab"), Key("c.d"), Index(1), Key("x"), Key("")}, tokens)
+	assert.Equal(t, []int{0, 3, 7, 10, 16}, offsets)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `["a.b"]`, Quote("a.b", '"'))
+	assert.Equal(t, `["a\"\\"]`, Quote(`a"\`, '"'))
+	assert.Equal(t, `['a"\'']`, Quote(`a"'`, '\''))
+	assert.True(t, NeedsQuoting("a.b", '.'))
+	assert.True(t, NeedsQuoting("a[", '/'))
+	assert.False(t, NeedsQuoting("a.b", '/'))
+}
+
+func TestCompile(t *testing.T) {
+	tokens, err := Compile("a[1].b", '.')
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("a"), Index(1), Key("b")}, tokens)
+
+	_, err = Compile("a[x].b", '.')
+	assert.Equal(t, &SyntaxError{Query: "a[x].b", Pos: 1, Msg: "expected index in square brackets"}, err)
+	_, err = Compile("ключ[1]b", '.')
+	assert.Equal(t, 7, err.(*SyntaxError).Pos)
+	_, err = Compile(`a["b`, '.')
+	assert.Equal(t, &SyntaxError{Query: `a["b`, Pos: 1, Msg: "unterminated quoted key"}, err)
+	_, err = Compile(`a\`, '.')
+	assert.Equal(t, 1, err.(*SyntaxError).Pos)
+	_, err = Compile(`a.{b,c`, '.')
+	assert.Equal(t, &SyntaxError{Query: `a.{b,c`, Pos: 2, Msg: `expected "}" at the end of multi-select`}, err)
+	_, err = Compile("a|lenght", '.')
+	assert.Equal(t, &SyntaxError{Query: "a|lenght", Pos: 2, Msg: `unknown function "lenght"`}, err)
+	_, err = Compile("a|sort|keys(x)", '.')
+	assert.Equal(t, &SyntaxError{Query: "a|sort|keys(x)", Pos: 7, Msg: "keys takes no arguments"}, err)
+	_, err = Compile("a|sort(", '.')
+	assert.Equal(t, 2, err.(*SyntaxError).Pos)
+	tokens, err = Compile("a|b", '|')
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("a"), Key("b")}, tokens)
+}
+
+func TestEval(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": []interface{}{"x", map[string]interface{}{"b": nil}},
+		"c": "str",
+	}
+	tbl := []struct {
+		path  []Token
+		node  interface{}
+		match Match
+	}{
+		{path: []Token{Key("c")}, node: "str", match: Full},
+		{path: []Token{Key("a"), Index(1), Key("b")}, node: nil, match: Full},
+		{path: []Token{Key("a"), Index(2)}, node: nil, match: NoMatch},
+		{path: []Token{Key("x")}, node: doc, match: Partial},
+		{path: []Token{Key("x"), Key("y")}, node: nil, match: NoMatch},
+		{path: []Token{Key("a"), ErrIndex("[1")}, node: doc["a"], match: Partial},
+		{path: []Token{Key("c"), Key("")}, node: "str", match: Partial},
+	}
+	for _, tt := range tbl {
+		node, match := Eval(doc, tt.path)
+		assert.Equal(t, tt.node, node, "%v", tt.path)
+		assert.Equal(t, tt.match, match, "%v", tt.path)
+	}
+
+	arr := []interface{}{1, 2}
+	node, match := Eval(arr, []Token{Key(""), Index(1)})
+	assert.Equal(t, 2, node)
+	assert.Equal(t, Full, match)
+}
+
+func TestEval_projection(t *testing.T) {
+	doc := map[string]interface{}{
+		"user": map[string]interface{}{"id": 1, "name": "a", "email": "a@b"},
+		"items": []interface{}{
+			map[string]interface{}{"id": 1, "status": "ok", "tag": "x"},
+			map[string]interface{}{"id": 2},
+			"str",
+		},
+	}
+	tbl := []struct {
+		path  []Token
+		node  interface{}
+		match Match
+	}{
+		{
+			path:  []Token{Key("user"), MultiSelect{"id", "name"}},
+			node:  map[string]interface{}{"id": 1, "name": "a"},
+			match: Full,
+		},
+		{
+			path:  []Token{Key("user"), MultiSelect{"id", "phone"}},
+			node:  map[string]interface{}{"id": 1, "phone": nil},
+			match: Full,
+		},
+		{
+			path:  []Token{Key("items"), ArrayAsterisk, Key("id")},
+			node:  []interface{}{1, 2},
+			match: Full,
+		},
+		{
+			path: []Token{Key("items"), ArrayAsterisk, MultiSelect{"id", "status"}},
+			node: []interface{}{
+				map[string]interface{}{"id": 1, "status": "ok"},
+				map[string]interface{}{"id": 2, "status": nil},
+			},
+			match: Full,
+		},
+		{
+			path:  []Token{Key("items"), ArrayAsterisk, Key("st")},
+			node:  doc["items"],
+			match: Partial,
+		},
+		{path: []Token{Key("user"), ErrMultiSelect("{id,na")}, node: doc["user"], match: Partial},
+		{path: []Token{Key("items"), ArrayAsterisk, Index(0)}, node: nil, match: NoMatch},
+		{path: []Token{Key("user"), ArrayAsterisk}, node: nil, match: NoMatch},
+		{path: []Token{Key("items"), MultiSelect{"id"}}, node: nil, match: NoMatch},
+	}
+	for _, tt := range tbl {
+		node, match := Eval(doc, tt.path)
+		assert.Equal(t, tt.node, node, "%v", tt.path)
+		assert.Equal(t, tt.match, match, "%v", tt.path)
+	}
+
+	node, match := Eval([]interface{}{}, []Token{Key(""), ArrayAsterisk, Key("id")})
+	assert.Equal(t, []interface{}{}, node)
+	assert.Equal(t, Full, match)
+}
+
+func TestGet(t *testing.T) {
+	doc := map[string]interface{}{"a": []interface{}{"x"}}
+	node, err := Get(doc, []Token{Key("a"), Index(0)})
+	assert.NoError(t, err)
+	assert.Equal(t, "x", node)
+
+	_, err = Get(doc, []Token{Key("a"), Index(3), Key("b")})
+	assert.Equal(t, &NotFoundError{Path: []Token{Key("a"), Index(3), Key("b")}, Step: 1}, err)
+	assert.EqualError(t, err, `path not found: no index [3] in "a"`)
+	_, err = Get(doc, []Token{Key("b")})
+	assert.EqualError(t, err, `path not found: no key "b" in the root`)
+}
+
+func TestCompletions(t *testing.T) {
+	doc := map[string]interface{}{"abc": 1, "abd": 2, "x": 3}
+	assert.Equal(t, []string{"abc", "abd"}, Completions(doc, []Token{Key("ab")}))
+	assert.Nil(t, Completions(doc, []Token{Key("z")}))
+	assert.Nil(t, Completions("str", []Token{Key("")}))
+	assert.Equal(t, []string{"[12]"}, Completions([]interface{}{}, []Token{Key("a"), ErrIndex("[12")}))
+	assert.Equal(t, "c", BestCompletion(Key("ab"), []string{"abc", "abd"}))
+
+	doc = map[string]interface{}{"a.b": 1, "a.c": 2, "x": 3}
+	assert.Equal(t, []string{`["a.b"]`, `["a.c"]`}, Completions(doc, []Token{Key(""), ErrQuotedKey(`["a.`)}))
+	assert.Equal(t, []string{`['a.b']`}, Completions(doc, []Token{Key(""), ErrQuotedKey(`['a.b`)}))
+	assert.Nil(t, Completions(doc, []Token{Key(""), ErrQuotedKey(`["a"x`)}))
+
+	user := map[string]interface{}{"id": 1, "name": "a", "nick": "b"}
+	assert.Equal(t, []string{"name", "nick"}, Completions(user, []Token{Key("user"), ErrMultiSelect("{id,n")}))
+	assert.Equal(t, []string{"nick"}, Completions(user, []Token{Key("user"), ErrMultiSelect("{name, id,")}))
+	assert.Equal(t, "ame", BestCompletion(ErrMultiSelect("{id,n"), []string{"name"}))
+
+	elements := []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"status": 2}}
+	assert.Equal(t, []string{"id", "status"}, Completions(elements, []Token{Key("items"), ArrayAsterisk, Key("")}))
+	assert.Equal(t, []string{"status"}, Completions(elements, []Token{Key("items"), ArrayAsterisk, ErrMultiSelect("{id,")}))
+}
+
+func TestCompletions_funcs(t *testing.T) {
+	assert.Equal(t, []string{"first"}, Completions(nil, []Token{Key("a"), Call{Name: "fi"}}))
+	assert.Len(t, Completions(nil, []Token{Key("a"), Call{}}), len(Funcs()))
+	assert.Nil(t, Completions(nil, []Token{Key("a"), Call{Name: "x", Args: []string{}}}))
+	assert.Equal(t, "gth", BestCompletion(Call{Name: "len"}, []string{"length"}))
+	assert.Equal(t, "a|length", Complete("a|len", '.', "length"))
+}
+
+func TestComplete(t *testing.T) {
+	assert.Equal(t, "user.name", Complete("user.na", '.', "name"))
+	assert.Equal(t, `user["a.b"]`, Complete("user.a", '.', "a.b"))
+	assert.Equal(t, "a[12]", Complete("a[1", '.', "[12]"))
+	assert.Equal(t, "user.{id,name", Complete("user.{id,n", '.', "name"))
+	assert.Equal(t, `user.{id,a\,b`, Complete("user.{id,", '.', "a,b"))
+	// spaces around keys are kept when they are escaped
+	raw := Complete("user.{id,", '.', " a ")
+	assert.Equal(t, `user.{id,\ a\ `, raw)
+	assert.Equal(t, `user.{id,\ a\ `, Complete(`user.{id,\ `, '.', " a "))
+	tokens, _ := Parse(raw+"}", '.')
+	assert.Equal(t, []Token{Key("user"), MultiSelect{"id", " a "}}, tokens)
+	picked, prefix := ErrMultiSelect(`{ id ,\ a`).Keys()
+	assert.Equal(t, []string{"id"}, picked)
+	assert.Equal(t, " a", prefix)
+}
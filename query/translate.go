@@ -0,0 +1,321 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var identRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jqFuncs are the jq filters equivalent to the built-in functions
+var jqFuncs = map[string]string{
+	"length":   "length",
+	"keys":     "keys",
+	"values":   "[keys[] as $k | .[$k]]",
+	"type":     "type",
+	"sort":     "sort",
+	"unique":   "unique",
+	"first":    ".[0]",
+	"last":     ".[-1]",
+	"sum":      "map(select(. != null)) | add // 0",
+	"avg":      "map(select(. != null)) | if length == 0 then null else add / length end",
+	"min":      "min",
+	"max":      "max",
+	"count_by": `group_by(.%[1]s) | map({key: (.[0].%[1]s | if type == "string" then . else tojson end), value: length}) | from_entries`,
+	"group_by": `group_by(.%[1]s) | map({key: (.[0].%[1]s | if type == "string" then . else tojson end), value: .}) | from_entries`,
+}
+
+// rootPath strips the empty key that queries to the root array and to quoted keys start with
+func rootPath(path []Token) []Token {
+	if len(path) > 0 && path[0] == Token(Key("")) {
+		return path[1:]
+	}
+	return path
+}
+
+// splitCalls splits the path into the steps and the functions applied to their result
+func splitCalls(path []Token) ([]Token, []Token) {
+	for i, tok := range path {
+		switch tok.(type) {
+		case Call, ErrCall:
+			return path[:i], path[i:]
+		}
+	}
+	return path, nil
+}
+
+// incomplete returns an error if the path contains an unparsable part
+func incomplete(path []Token) error {
+	for _, tok := range path {
+		switch t := tok.(type) {
+		case ErrKey, ErrIndex, ErrQuotedKey, ErrMultiSelect, ErrCall:
+			return fmt.Errorf("cannot translate incomplete query %q", t)
+		}
+	}
+	return nil
+}
+
+// jsonString quotes the string as a JSON string literal
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ToJq translates the path into a jq filter. Projections are collected into arrays,
+// e.g. items[*].id becomes [.items[] | .id]. Unlike in vuje, in jq elements
+// without the projected key give null instead of being skipped
+func ToJq(path []Token) (string, error) {
+	if err := incomplete(path); err != nil {
+		return "", err
+	}
+	steps, calls := splitCalls(rootPath(path))
+	var pipeline []string
+	if filter := jqFilter(steps); filter != "." || len(calls) == 0 {
+		pipeline = append(pipeline, filter)
+	}
+	for _, tok := range calls {
+		c := tok.(Call)
+		if err := checkCall(c); err != nil {
+			return "", err
+		}
+		var args []interface{}
+		for _, arg := range c.Args {
+			args = append(args, jqKey(arg, true))
+		}
+		f, ok := jqFuncs[c.Name]
+		if !ok {
+			return "", fmt.Errorf("%s has no jq equivalent", c.Name)
+		}
+		if len(args) > 0 {
+			f = fmt.Sprintf(f, args...)
+		}
+		pipeline = append(pipeline, f)
+	}
+	return strings.Join(pipeline, " | "), nil
+}
+
+// jqKey returns the key as it follows a dot in jq: either the name or a quoted string
+func jqKey(key string, afterDot bool) string {
+	if identRegex.MatchString(key) {
+		return key
+	}
+	if afterDot {
+		return jsonString(key)
+	}
+	return "[" + jsonString(key) + "]"
+}
+
+func jqFilter(path []Token) string {
+	var pipeline []string
+	seg := ""
+	for i, tok := range path {
+		switch t := tok.(type) {
+		case Key:
+			if identRegex.MatchString(string(t)) || seg == "" {
+				seg += "." + jqKey(string(t), true)
+			} else {
+				seg += jqKey(string(t), false)
+			}
+		case Index:
+			if seg == "" {
+				seg = "."
+			}
+			seg += "[" + strconv.Itoa(int(t)) + "]"
+		case Wildcard:
+			if seg == "" {
+				seg = "."
+			}
+			inner := seg + "[]"
+			if rest := jqFilter(path[i+1:]); rest != "." {
+				inner += " | " + rest
+			}
+			return strings.Join(append(pipeline, "["+inner+"]"), " | ")
+		case MultiSelect:
+			if seg != "" {
+				pipeline = append(pipeline, seg)
+				seg = ""
+			}
+			var fields []string
+			for _, k := range t {
+				if identRegex.MatchString(k) {
+					fields = append(fields, k)
+				} else {
+					fields = append(fields, jsonString(k)+": ."+jqKey(k, true))
+				}
+			}
+			pipeline = append(pipeline, "{"+strings.Join(fields, ", ")+"}")
+		}
+	}
+	if seg != "" {
+		pipeline = append(pipeline, seg)
+	}
+	if len(pipeline) == 0 {
+		return "."
+	}
+	return strings.Join(pipeline, " | ")
+}
+
+// ToJSONPath translates the path into a JSONPath expression, e.g. $.items[*]['file.name'].
+// Multi-selects and functions have no equivalent in JSONPath
+func ToJSONPath(path []Token) (string, error) {
+	if err := incomplete(path); err != nil {
+		return "", err
+	}
+	expr := "$"
+	for _, tok := range rootPath(path) {
+		switch t := tok.(type) {
+		case Key:
+			if identRegex.MatchString(string(t)) {
+				expr += "." + string(t)
+				continue
+			}
+			expr += Quote(string(t), '\'')
+		case Index:
+			expr += "[" + strconv.Itoa(int(t)) + "]"
+		case Wildcard:
+			expr += "[*]"
+		case MultiSelect:
+			return "", fmt.Errorf("multi-select %s has no JSONPath equivalent", Format([]Token{t}, '.'))
+		case Call:
+			return "", fmt.Errorf("function %s has no JSONPath equivalent", t.Name)
+		}
+	}
+	return expr, nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// ToPointer translates the path into an RFC 6901 JSON Pointer, e.g. /items/0/name.
+// Only keys and indices can be expressed in pointers
+func ToPointer(path []Token) (string, error) {
+	if err := incomplete(path); err != nil {
+		return "", err
+	}
+	ptr := ""
+	for _, tok := range rootPath(path) {
+		switch t := tok.(type) {
+		case Key:
+			ptr += "/" + pointerEscaper.Replace(string(t))
+		case Index:
+			ptr += "/" + strconv.Itoa(int(t))
+		case Wildcard:
+			return "", fmt.Errorf("wildcard %s has no JSON Pointer equivalent", t)
+		case MultiSelect:
+			return "", fmt.Errorf("multi-select %s has no JSON Pointer equivalent", Format([]Token{t}, '.'))
+		case Call:
+			return "", fmt.Errorf("function %s has no JSON Pointer equivalent", t.Name)
+		}
+	}
+	return ptr, nil
+}
+
+var (
+	pointerIndex = regexp.MustCompile(`^(0|[1-9]\d*)$`)
+	badTilde     = regexp.MustCompile(`~([^01]|$)`)
+)
+
+// FromPointer parses an RFC 6901 JSON Pointer into a path. The pointer does not tell
+// keys from indices, so numeric steps are indices only where the document has an array
+func FromPointer(ptr string, doc interface{}) ([]Token, error) {
+	path := []Token{}
+	if ptr == "" {
+		return path, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, &SyntaxError{Query: ptr, Pos: 0, Msg: `JSON Pointer must start with "/"`}
+	}
+	node := doc
+	pos := 1
+	for _, step := range strings.Split(ptr[1:], "/") {
+		if badTilde.MatchString(step) {
+			return nil, &SyntaxError{Query: ptr, Pos: pos, Msg: `"~" must be followed by "0" or "1"`}
+		}
+		key := strings.Replace(strings.Replace(step, "~1", "/", -1), "~0", "~", -1)
+		switch t := node.(type) {
+		case []interface{}:
+			if !pointerIndex.MatchString(key) {
+				return nil, &SyntaxError{Query: ptr, Pos: pos, Msg: fmt.Sprintf("expected array index, got %q", key)}
+			}
+			idx, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, &SyntaxError{Query: ptr, Pos: pos, Msg: err.Error()}
+			}
+			path = append(path, Index(idx))
+			node = nil
+			if idx < len(t) {
+				node = t[idx]
+			}
+		case map[string]interface{}:
+			path = append(path, Key(key))
+			node = t[key]
+		default:
+			if idx, err := strconv.Atoi(key); err == nil && pointerIndex.MatchString(key) {
+				path = append(path, Index(idx))
+			} else {
+				path = append(path, Key(key))
+			}
+			node = nil
+		}
+		pos += len([]rune(step)) + 1
+	}
+	return path, nil
+}
+
+// FromJSONPath parses a simple JSONPath expression made of keys, indices and [*],
+// e.g. $.items[*]['name']. Filters, slices, unions and recursive descent are not supported
+func FromJSONPath(expr string) ([]Token, error) {
+	q := []rune(expr)
+	fail := func(pos int, msg string) ([]Token, error) {
+		return nil, &SyntaxError{Query: expr, Pos: pos, Msg: msg}
+	}
+	if len(q) == 0 || q[0] != '$' {
+		return fail(0, `JSONPath must start with "$"`)
+	}
+	path := []Token{}
+	for i := 1; i < len(q); {
+		switch {
+		case strings.HasPrefix(string(q[i:]), ".."):
+			return fail(i, "recursive descent is not supported")
+		case strings.HasPrefix(string(q[i:]), ".*"):
+			// in JSONPath it also selects the values of objects, vuje projects arrays only
+			return fail(i, `".*" is not supported, use "[*]" for the elements of arrays`)
+		case q[i] == '.':
+			end := i + 1
+			for end < len(q) && q[end] != '.' && q[end] != '[' {
+				end++
+			}
+			if end == i+1 {
+				return fail(i, "expected key after \".\"")
+			}
+			path = append(path, Key(q[i+1:end]))
+			i = end
+		case q[i] == '[':
+			tok, size := parseBrackets(q[i:])
+			if size == -1 {
+				return fail(i, "only keys in quotes, indices and [*] are supported in brackets")
+			}
+			path = append(path, tok)
+			i += size
+		default:
+			return fail(i, `expected "." or "["`)
+		}
+	}
+	return path, nil
+}
+
+// IsPointer reports whether the raw query looks like a JSON Pointer rather than a vuje query
+func IsPointer(raw string) bool {
+	return strings.HasPrefix(raw, "/")
+}
+
+// IsJSONPath reports whether the raw query looks like a JSONPath expression rather than a vuje query
+func IsJSONPath(raw string) bool {
+	return raw == "$" || strings.HasPrefix(raw, "$.") || strings.HasPrefix(raw, "$[")
+}
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	tbl := []struct {
+		query, jq, jsonPath, pointer string
+	}{
+		{query: "", jq: ".", jsonPath: "$", pointer: ""},
+		{query: "a.b[1]", jq: ".a.b[1]", jsonPath: "$.a.b[1]", pointer: "/a/b/1"},
+		{query: "[0].a", jq: ".[0].a", jsonPath: "$[0].a", pointer: "/0/a"},
+		{query: `["a/b~"].c["x y"]`, jq: `."a/b~".c["x y"]`, jsonPath: `$['a/b~'].c['x y']`, pointer: "/a~1b~0/c/x y"},
+		{query: `["it's"]`, jq: `."it's"`, jsonPath: `$['it\'s']`, pointer: "/it's"},
+		{query: "items[*].id", jq: "[.items[] | .id]", jsonPath: "$.items[*].id"},
+		{query: "[*]", jq: "[.[]]", jsonPath: "$[*]"},
+		{query: "a[*].b[*].c", jq: "[.a[] | [.b[] | .c]]", jsonPath: "$.a[*].b[*].c"},
+		{query: `user.{id,"x}`, jq: `.user | {id, "\"x": ."\"x"}`},
+		{query: "items[*].{id,status}", jq: "[.items[] | {id, status}]"},
+		{query: "items[*].tag|unique|sort", jq: "[.items[] | .tag] | unique | sort"},
+		{query: "|keys", jq: "keys"},
+		{query: "a|first", jq: ".a | .[0]"},
+		{
+			query: "events|count_by(status)",
+			jq:    `.events | group_by(.status) | map({key: (.[0].status | if type == "string" then . else tojson end), value: length}) | from_entries`,
+		},
+	}
+	for _, tt := range tbl {
+		path, err := Compile(tt.query, '.')
+		assert.NoError(t, err, tt.query)
+		jq, err := ToJq(path)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.jq, jq, tt.query)
+		if tt.jsonPath != "" {
+			jsonPath, err := ToJSONPath(path)
+			assert.NoError(t, err, tt.query)
+			assert.Equal(t, tt.jsonPath, jsonPath, tt.query)
+		}
+		if tt.pointer != "" || tt.query == "" {
+			pointer, err := ToPointer(path)
+			assert.NoError(t, err, tt.query)
+			assert.Equal(t, tt.pointer, pointer, tt.query)
+		}
+	}
+
+	_, err := ToPointer([]Token{Key("a"), ArrayAsterisk})
+	assert.EqualError(t, err, "wildcard [*] has no JSON Pointer equivalent")
+	_, err = ToJSONPath([]Token{Key("a"), MultiSelect{"b", "c"}})
+	assert.EqualError(t, err, "multi-select {b,c} has no JSONPath equivalent")
+	_, err = ToJSONPath([]Token{Key("a"), Call{Name: "length"}})
+	assert.EqualError(t, err, "function length has no JSONPath equivalent")
+	_, err = ToJq([]Token{Key("a"), ErrIndex("[x")})
+	assert.EqualError(t, err, `cannot translate incomplete query "[x"`)
+}
+
+func TestFromPointer(t *testing.T) {
+	doc := map[string]interface{}{
+		"a":   []interface{}{map[string]interface{}{"0": 1}},
+		"b/c": 1,
+	}
+	path, err := FromPointer("/a/0/0", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("a"), Index(0), Key("0")}, path)
+
+	path, err = FromPointer("/b~1c", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("b/c")}, path)
+
+	path, err = FromPointer("", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{}, path)
+
+	// steps missing in the document are indices when they are numbers
+	path, err = FromPointer("/x/1/y", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("x"), Index(1), Key("y")}, path)
+
+	_, err = FromPointer("/a/-", doc)
+	assert.Equal(t, &SyntaxError{Query: "/a/-", Pos: 3, Msg: `expected array index, got "-"`}, err)
+	_, err = FromPointer("/a~2", doc)
+	assert.Equal(t, &SyntaxError{Query: "/a~2", Pos: 1, Msg: `"~" must be followed by "0" or "1"`}, err)
+	_, err = FromPointer("a", doc)
+	assert.Error(t, err)
+}
+
+func TestFromJSONPath(t *testing.T) {
+	path, err := FromJSONPath(`$.items[*]['file.name'][2].x[*]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{Key("items"), ArrayAsterisk, Key("file.name"), Index(2), Key("x"), ArrayAsterisk}, path)
+	_, err = FromJSONPath("$.k.*")
+	assert.Equal(t, &SyntaxError{Query: "$.k.*", Pos: 3, Msg: `".*" is not supported, use "[*]" for the elements of arrays`}, err)
+
+	path, err = FromJSONPath("$")
+	assert.NoError(t, err)
+	assert.Equal(t, []Token{}, path)
+
+	_, err = FromJSONPath("$..name")
+	assert.Equal(t, &SyntaxError{Query: "$..name", Pos: 1, Msg: "recursive descent is not supported"}, err)
+	_, err = FromJSONPath("$.a[?(@.b)]")
+	assert.Equal(t, 3, err.(*SyntaxError).Pos)
+	_, err = FromJSONPath("$.")
+	assert.Error(t, err)
+
+	assert.True(t, IsJSONPath("$.a"))
+	assert.False(t, IsJSONPath("$schema"))
+	assert.True(t, IsPointer("/a"))
+	assert.False(t, IsPointer("a/b"))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/pkg/errors"
+	"github.com/qoops-1/vuje/query"
+)
+
+// redactedMask replaces the secrets
+const redactedMask = "<redacted>"
+
+// secretKeys are the patterns of the names of keys that hold secrets, "*" matches any text
+var secretKeys = []string{
+	"*password*", "*passwd*", "*secret*", "*token", "*api_key", "*apikey", "*private_key",
+	"authorization", "cookie", "credentials",
+}
+
+// secretValues are the patterns of secrets found in any string
+var secretValues = []string{
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`,
+	// JSON Web Tokens
+	`\beyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`,
+	// GitHub and Slack tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}`,
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	// PEM private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z ]*PRIVATE KEY-----|$)`,
+}
+
+// redactor masks the values of the keys that hold secrets and the parts of strings that look like ones
+type redactor struct {
+	keys   []*regexp.Regexp
+	values []*regexp.Regexp
+}
+
+// newRedactor adds the key name globs and the value regular expressions to the default ones
+func newRedactor(keys, values []string) (*redactor, error) {
+	r := &redactor{}
+	for _, glob := range append(append([]string{}, secretKeys...), keys...) {
+		expr := strings.Replace(regexp.QuoteMeta(glob), `\*`, `.*`, -1)
+		r.keys = append(r.keys, regexp.MustCompile(`(?i)^`+expr+`$`))
+	}
+	for _, expr := range append(append([]string{}, secretValues...), values...) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret value pattern %q", expr)
+		}
+		r.values = append(r.values, re)
+	}
+	return r, nil
+}
+
+// secretKey reports whether the key holds a secret
+func (r *redactor) secretKey(key string) bool {
+	for _, re := range r.keys {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretPath reports whether the node at the path is stored under a key that holds a secret.
+// Functions do not matter, so the length of a password is masked as well
+func (r *redactor) secretPath(path []query.Token) bool {
+	for _, tok := range path {
+		switch t := tok.(type) {
+		case query.Key:
+			if r.secretKey(string(t)) {
+				return true
+			}
+		case query.Call, query.ErrCall:
+			return false
+		}
+	}
+	return false
+}
+
+// secretPointer reports whether the JSON Pointer goes through a key that holds a secret
+func (r *redactor) secretPointer(ptr string) bool {
+	for _, step := range strings.Split(ptr, "/")[1:] {
+		if r.secretKey(pointerUnescaper.Replace(step)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the value with the secrets masked and whether anything was masked. Strings
+// and numbers are masked in secret values, the keys, booleans and nulls are kept. The value
+// is not changed, the containers on the way to the masked values are copied
+func (r *redactor) redact(v interface{}, secret bool) (interface{}, bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		var obj map[string]interface{}
+		for k, el := range t {
+			masked, changed := r.redact(el, secret || r.secretKey(k))
+			if !changed {
+				continue
+			}
+			if obj == nil {
+				obj = make(map[string]interface{}, len(t))
+				for k, el := range t {
+					obj[k] = el
+				}
+			}
+			obj[k] = masked
+		}
+		if obj == nil {
+			return v, false
+		}
+		return obj, true
+	case []interface{}:
+		var arr []interface{}
+		for i, el := range t {
+			masked, changed := r.redact(el, secret)
+			if !changed {
+				continue
+			}
+			if arr == nil {
+				arr = append([]interface{}{}, t...)
+			}
+			arr[i] = masked
+		}
+		if arr == nil {
+			return v, false
+		}
+		return arr, true
+	case string:
+		if secret {
+			return redactedMask, t != redactedMask
+		}
+		masked := r.maskString(t)
+		return masked, masked != t
+	case json.Number, float64, int:
+		if secret {
+			return redactedMask, true
+		}
+	}
+	return v, false
+}
+
+// maskString masks the parts of the text that look like secrets
+func (r *redactor) maskString(s string) string {
+	for _, re := range r.values {
+		s = re.ReplaceAllString(s, redactedMask)
+	}
+	return s
+}
+
+// redactChanges masks the values of the changes found at the path
+func (r *redactor) redactChanges(path []query.Token, changes []Change) []Change {
+	masked := make([]Change, len(changes))
+	for i, c := range changes {
+		secret := r.secretPath(path) || r.secretPath(c.Path) || r.secretPath(c.From)
+		c.Old, _ = r.redact(c.Old, secret)
+		c.New, _ = r.redact(c.New, secret)
+		masked[i] = c
+	}
+	return masked
+}
+
+// redactPatch masks the values of a JSON Patch or a merge patch
+func (r *redactor) redactPatch(patch interface{}) interface{} {
+	ops, ok := patch.([]patchOp)
+	if !ok {
+		masked, _ := r.redact(patch, false)
+		return masked
+	}
+	masked := make([]patchOp, len(ops))
+	for i, op := range ops {
+		if op.Value != nil {
+			if v, err := parseValue(string(op.Value)); err == nil {
+				v, _ = r.redact(v, r.secretPointer(op.Path))
+				op.Value = json.RawMessage(compactJSON(v))
+			}
+		}
+		masked[i] = op
+	}
+	return masked
+}
+
+// listFlag collects the values of a flag that can be repeated. With split set,
+// the values are lists separated by commas
+type listFlag struct {
+	list  *[]string
+	split bool
+}
+
+func (f listFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f listFlag) Set(s string) error {
+	if !f.split {
+		*f.list = append(*f.list, s)
+		return nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f.list = append(*f.list, item)
+		}
+	}
+	return nil
+}
+
+// SetRedactor masks the secrets in the views and in the output. They can be revealed
+// in the explorer, but the printed results are always masked
+func (e *Explorer) SetRedactor(r *redactor) {
+	e.redactor = r
+}
+
+// hidden reports whether the secrets are masked in the views
+func (e *Explorer) hidden() bool {
+	return e.redactor != nil && !e.reveal
+}
+
+// masked returns the node at the path with the secrets masked unless they are revealed
+func (e *Explorer) masked(node interface{}, path []query.Token) interface{} {
+	if !e.hidden() {
+		return node
+	}
+	node, _ = e.redactor.redact(node, e.redactor.secretPath(path))
+	return node
+}
+
+// masksSecrets reports whether the displayed node has secrets that are masked in the views
+func (e *Explorer) masksSecrets(node interface{}) bool {
+	if !e.hidden() {
+		return false
+	}
+	_, secret := e.redactor.redact(node, e.redactor.secretPath(e.docPath))
+	return secret
+}
+
+// exported returns the node at the path as it can be printed, always with the secrets masked
+func (e *Explorer) exported(node *simplejson.Json, path []query.Token) *simplejson.Json {
+	if e.redactor == nil || node == nil {
+		return node
+	}
+	masked, _ := e.redactor.redact(node.Interface(), e.redactor.secretPath(path))
+	return jsonOf(masked)
+}
+
+// violationMsg returns the message of the violation, values of secrets are not quoted in it
+func (e *Explorer) violationMsg(v Violation) string {
+	switch {
+	case !e.hidden():
+		return v.Msg
+	case e.redactor.secretPath(v.Path):
+		return "the value is " + redactedMask
+	default:
+		return e.redactor.maskString(v.Msg)
+	}
+}
+
+// toggleReveal shows or masks the secrets in the views, the output stays masked
+func (e *Explorer) toggleReveal() {
+	if e.redactor == nil {
+		e.message = "nothing is redacted, start vuje with -redact"
+		return
+	}
+	e.reveal = !e.reveal
+	e.shapes = nil
+	e.diffs = nil
+	e.syncWithQuery()
+	if e.reveal {
+		e.message = "secrets are revealed, F12 masks them again"
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// docFormat is the layout of the input kept when the edited document is saved:
+// the indentation, the trailing newline and the order of keys in objects
+type docFormat struct {
+	// indent is empty for documents written on one line
+	indent  string
+	newline bool
+	// keyOrder lists the keys of the objects by their JSON Pointers
+	keyOrder map[string][]string
+}
+
+// detectFormat finds out the layout of the JSON document
+func detectFormat(data []byte) *docFormat {
+	f := &docFormat{newline: bytes.HasSuffix(data, []byte("\n")), keyOrder: map[string][]string{}}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) > 1 {
+		f.indent = "  "
+		for _, line := range lines[1:] {
+			content := bytes.TrimLeft(line, " \t")
+			if lead := line[:len(line)-len(content)]; len(lead) > 0 && len(bytes.TrimSpace(content)) > 0 {
+				f.indent = string(lead)
+				break
+			}
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := f.readKeys(dec, ""); err != nil {
+		f.keyOrder = map[string][]string{}
+	}
+	return f
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// readKeys records the order of keys of the value read from the decoder and the values nested in it
+func (f *docFormat) readKeys(dec *json.Decoder, ptr string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var keys []string
+		for dec.More() {
+			if tok, err = dec.Token(); err != nil {
+				return err
+			}
+			key := tok.(string)
+			keys = append(keys, key)
+			if err := f.readKeys(dec, ptr+"/"+pointerEscaper.Replace(key)); err != nil {
+				return err
+			}
+		}
+		f.keyOrder[ptr] = keys
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := f.readKeys(dec, ptr+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+// renamed keeps the position of a key renamed with a move from one pointer to another
+func (f *docFormat) renamed(from, to string) {
+	i, j := strings.LastIndex(from, "/"), strings.LastIndex(to, "/")
+	if i < 0 || j < 0 || from[:i] != to[:j] {
+		return
+	}
+	keys := f.keyOrder[from[:i]]
+	for k, key := range keys {
+		if pointerEscaper.Replace(key) == from[i+1:] {
+			keys[k] = pointerUnescaper.Replace(to[j+1:])
+		}
+	}
+	var moved []string
+	for ptr := range f.keyOrder {
+		if ptr == from || strings.HasPrefix(ptr, from+"/") {
+			moved = append(moved, ptr)
+		}
+	}
+	for _, ptr := range moved {
+		f.keyOrder[to+ptr[len(from):]] = f.keyOrder[ptr]
+		delete(f.keyOrder, ptr)
+	}
+}
+
+// orderedKeys returns the keys of the object in the order they had in the input,
+// keys that were not there follow sorted
+func (f *docFormat) orderedKeys(ptr string, obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	seen := map[string]bool{}
+	for _, k := range f.keyOrder[ptr] {
+		if _, ok := obj[k]; ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	var rest []string
+	for k := range obj {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// encode writes the document in the layout of the input
+func (f *docFormat) encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.write(&buf, v, "", 0); err != nil {
+		return nil, err
+	}
+	if f.newline {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *docFormat) write(buf *bytes.Buffer, v interface{}, ptr string, depth int) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, k := range f.orderedKeys(ptr, t) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			f.lineBreak(buf, depth+1)
+			buf.WriteString(compactJSON(k))
+			buf.WriteByte(':')
+			if f.indent != "" {
+				buf.WriteByte(' ')
+			}
+			if err := f.write(buf, t[k], ptr+"/"+pointerEscaper.Replace(k), depth+1); err != nil {
+				return err
+			}
+		}
+		f.lineBreak(buf, depth)
+		buf.WriteByte('}')
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, el := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			f.lineBreak(buf, depth+1)
+			if err := f.write(buf, el, ptr+"/"+strconv.Itoa(i), depth+1); err != nil {
+				return err
+			}
+		}
+		f.lineBreak(buf, depth)
+		buf.WriteByte(']')
+	default:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	return nil
+}
+
+func (f *docFormat) lineBreak(buf *bytes.Buffer, depth int) {
+	if f.indent == "" {
+		return
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Repeat(f.indent, depth))
+}
+
+// writeFileAtomic replaces the file with the data so that readers see either
+// the old or the new contents. The permissions of an existing file are kept
+func writeFileAtomic(name string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// SetFile sets the file the edited document is saved to
+func (e *Explorer) SetFile(name string) {
+	e.file = name
+}
+
+// docFormat returns the layout of the input, it is detected when needed the first time
+func (e *Explorer) docFormat() *docFormat {
+	if e.format == nil {
+		e.format = detectFormat(e.source)
+		e.source = nil
+	}
+	return e.format
+}
+
+func (e *Explorer) save() {
+	if e.file == "" {
+		e.saveAs()
+		return
+	}
+	e.saveTo(e.file)
+}
+
+func (e *Explorer) saveAs() {
+	e.ask("save to: ", e.file, func(e *Explorer, name string) {
+		if name == "" {
+			return
+		}
+		e.saveTo(name)
+	})
+}
+
+func (e *Explorer) saveTo(name string) {
+	data, err := e.docFormat().encode(e.doc.Interface())
+	if err == nil {
+		err = writeFileAtomic(name, data)
+	}
+	if err != nil {
+		e.message = errors.Wrapf(err, "cant save %s", name).Error()
+		return
+	}
+	e.file = name
+	e.saved = data
+	e.savedEdits = len(e.edits)
+	e.message = "saved " + humanSize(len(data)) + " to " + name
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind is a set of JSON types a value was seen with
+type Kind int
+
+const (
+	KindNull Kind = 1 << iota
+	KindBool
+	KindInt
+	KindFloat
+	KindString
+	KindArray
+	KindObject
+)
+
+// Shape is the type of a JSON value inferred from one or more examples of it.
+// Shapes of all the elements of an array are merged into one
+type Shape struct {
+	Kinds Kind
+	// Count is the number of values the shape was inferred from
+	Count int
+	// Objects is the number of objects among the values
+	Objects int
+	// Fields are the keys seen in the objects sorted by name
+	Fields []*Field
+	// Elem is the shape of the elements of the arrays, nil if all of them were empty
+	Elem *Shape
+	// Examples are up to maxExamples distinct scalar values encoded as JSON
+	Examples []string
+	// Min and Max are the smallest and the largest numbers, empty if no numbers were seen
+	Min, Max json.Number
+}
+
+// maxExamples is the number of distinct example values kept by a shape
+const maxExamples = 3
+
+// Field is a key of an object shape
+type Field struct {
+	Name  string
+	Shape *Shape
+	// Count is the number of objects the key was seen in
+	Count int
+}
+
+// inferShape returns the shape of a value decoded by encoding/json
+func inferShape(v interface{}) *Shape {
+	s := &Shape{}
+	s.add(v)
+	return s
+}
+
+// add merges the value into the shape
+func (s *Shape) add(v interface{}) {
+	s.Count++
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+	default:
+		s.example(v)
+	}
+	switch t := v.(type) {
+	case nil:
+		s.Kinds |= KindNull
+	case bool:
+		s.Kinds |= KindBool
+	case string:
+		s.Kinds |= KindString
+	case json.Number:
+		if strings.ContainsAny(string(t), ".eE") {
+			s.Kinds |= KindFloat
+		} else {
+			s.Kinds |= KindInt
+		}
+		s.bounds(t)
+	case float64:
+		if t >= -(1<<63) && t < 1<<63 && t == float64(int64(t)) {
+			s.Kinds |= KindInt
+		} else {
+			s.Kinds |= KindFloat
+		}
+		s.bounds(json.Number(strconv.FormatFloat(t, 'g', -1, 64)))
+	case int:
+		s.Kinds |= KindInt
+		s.bounds(json.Number(strconv.Itoa(t)))
+	case int64:
+		s.Kinds |= KindInt
+		s.bounds(json.Number(strconv.FormatInt(t, 10)))
+	case []interface{}:
+		s.Kinds |= KindArray
+		for _, el := range t {
+			if s.Elem == nil {
+				s.Elem = &Shape{}
+			}
+			s.Elem.add(el)
+		}
+	case map[string]interface{}:
+		s.Kinds |= KindObject
+		s.Objects++
+		for k, fv := range t {
+			s.field(k).add(fv)
+		}
+	}
+}
+
+// example remembers the scalar value unless enough distinct examples were seen
+func (s *Shape) example(v interface{}) {
+	if len(s.Examples) >= maxExamples {
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(v) != nil {
+		return
+	}
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	for _, ex := range s.Examples {
+		if ex == encoded {
+			return
+		}
+	}
+	s.Examples = append(s.Examples, encoded)
+}
+
+// bounds extends the range of the numbers seen with n
+func (s *Shape) bounds(n json.Number) {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return
+	}
+	if min, ok := new(big.Rat).SetString(string(s.Min)); !ok || r.Cmp(min) < 0 {
+		s.Min = n
+	}
+	if max, ok := new(big.Rat).SetString(string(s.Max)); !ok || r.Cmp(max) > 0 {
+		s.Max = n
+	}
+}
+
+// field returns the field with the name counting one more object it was seen in
+func (s *Shape) field(name string) *Shape {
+	i := sort.Search(len(s.Fields), func(i int) bool { return s.Fields[i].Name >= name })
+	if i == len(s.Fields) || s.Fields[i].Name != name {
+		s.Fields = append(s.Fields, nil)
+		copy(s.Fields[i+1:], s.Fields[i:])
+		s.Fields[i] = &Field{Name: name, Shape: &Shape{}}
+	}
+	s.Fields[i].Count++
+	return s.Fields[i].Shape
+}
+
+// Nullable reports whether null was seen along with other types
+func (s *Shape) Nullable() bool {
+	return s.Kinds&KindNull != 0 && s.Kinds != KindNull
+}
+
+// NonNull returns the kinds seen besides null
+func (s *Shape) NonNull() Kind {
+	return s.Kinds &^ KindNull
+}
+
+// Optional reports whether the field was missing in some of the objects of the shape
+func (f *Field) Optional(parent *Shape) bool {
+	return f.Count < parent.Objects
+}
+
+// Names returns the JSON type names of the kinds, integers and floats are "integer" and "number"
+func (k Kind) Names() []string {
+	var names []string
+	for _, kn := range []struct {
+		kind Kind
+		name string
+	}{
+		{KindObject, "object"},
+		{KindArray, "array"},
+		{KindString, "string"},
+		{KindInt, "integer"},
+		{KindFloat, "number"},
+		{KindBool, "boolean"},
+		{KindNull, "null"},
+	} {
+		if k&kn.kind != 0 {
+			names = append(names, kn.name)
+		}
+	}
+	return names
+}
+
+// numeric merges integers with floats: a value seen as both is a float
+func (k Kind) numeric() Kind {
+	if k&KindFloat != 0 {
+		return k &^ KindInt
+	}
+	return k
+}
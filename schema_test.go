@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInferShape(t *testing.T) {
+	doc := []interface{}{
+		map[string]interface{}{"id": json.Number("1"), "v": json.Number("1"), "tags": []interface{}{}},
+		map[string]interface{}{"id": json.Number("2"), "v": json.Number("2.5"), "x": nil},
+		map[string]interface{}{"id": json.Number("3"), "v": nil, "x": "s"},
+	}
+	s := inferShape(doc)
+	assert.Equal(t, KindArray, s.Kinds)
+	assert.Equal(t, 1, s.Count)
+
+	elem := s.Elem
+	assert.Equal(t, 3, elem.Count)
+	assert.Equal(t, 3, elem.Objects)
+	var names []string
+	for _, f := range elem.Fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"id", "tags", "v", "x"}, names)
+
+	id, tags, v, x := elem.Fields[0], elem.Fields[1], elem.Fields[2], elem.Fields[3]
+	assert.Equal(t, KindInt, id.Shape.Kinds)
+	assert.False(t, id.Optional(elem))
+	assert.True(t, tags.Optional(elem))
+	assert.Nil(t, tags.Shape.Elem)
+	assert.Equal(t, KindInt|KindFloat|KindNull, v.Shape.Kinds)
+	assert.Equal(t, KindFloat, v.Shape.NonNull().numeric())
+	assert.True(t, v.Shape.Nullable())
+	assert.Equal(t, 2, x.Count)
+	assert.Equal(t, []string{"string", "null"}, x.Shape.Kinds.Names())
+
+	assert.False(t, inferShape(nil).Nullable())
+}
+
+func TestInferShape_examples(t *testing.T) {
+	s := inferShape([]interface{}{
+		json.Number("10"), json.Number("-2.5"), "a<b", json.Number("10"), nil, json.Number("1e3"), true,
+	}).Elem
+	assert.Equal(t, []string{"10", "-2.5", `"a<b"`}, s.Examples)
+	assert.Equal(t, json.Number("-2.5"), s.Min)
+	assert.Equal(t, json.Number("1e3"), s.Max)
+
+	s = inferShape(map[string]interface{}{"a": []interface{}{}})
+	assert.Nil(t, s.Examples)
+	assert.Equal(t, json.Number(""), s.Min)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// Screen is the terminal the explorer draws on and receives events from
+type Screen interface {
+	Init() error
+	Close()
+	Size() (width int, height int)
+	Clear(fg, bg termbox.Attribute) error
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	SetCursor(x, y int)
+	Flush() error
+	PollEvent() termbox.Event
+	// Interrupt makes PollEvent return an EventInterrupt, it can be called from any goroutine
+	Interrupt()
+	SetInputMode(mode termbox.InputMode) termbox.InputMode
+	SetOutputMode(mode termbox.OutputMode) termbox.OutputMode
+}
+
+// termboxScreen is the real terminal
+type termboxScreen struct{}
+
+func (termboxScreen) Init() error                          { return termbox.Init() }
+func (termboxScreen) Close()                               { termbox.Close() }
+func (termboxScreen) Size() (int, int)                     { return termbox.Size() }
+func (termboxScreen) Clear(fg, bg termbox.Attribute) error { return termbox.Clear(fg, bg) }
+func (termboxScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, fg, bg)
+}
+func (termboxScreen) SetCursor(x, y int)       { termbox.SetCursor(x, y) }
+func (termboxScreen) Flush() error             { return termbox.Flush() }
+func (termboxScreen) PollEvent() termbox.Event { return termbox.PollEvent() }
+func (termboxScreen) Interrupt()               { termbox.Interrupt() }
+func (termboxScreen) SetInputMode(mode termbox.InputMode) termbox.InputMode {
+	return termbox.SetInputMode(mode)
+}
+func (termboxScreen) SetOutputMode(mode termbox.OutputMode) termbox.OutputMode {
+	return termbox.SetOutputMode(mode)
+}
+
+// memScreen is an in-memory screen replaying a script of events. When the
+// script is over PollEvent returns an EventError with io.EOF
+type memScreen struct {
+	width, height    int
+	cells            []termbox.Cell
+	cursorX, cursorY int
+	events           []termbox.Event
+	inputMode        termbox.InputMode
+	outputMode       termbox.OutputMode
+	// onPoll is called before every event is returned, it is used to inspect the screen in tests
+	onPoll func(ev termbox.Event)
+}
+
+func newMemScreen(width, height int, events ...termbox.Event) *memScreen {
+	s := &memScreen{events: events}
+	s.resize(width, height)
+	return s
+}
+
+func (s *memScreen) resize(width, height int) {
+	s.width, s.height = width, height
+	s.cells = make([]termbox.Cell, width*height)
+	s.Clear(termbox.ColorDefault, termbox.ColorDefault)
+}
+
+func (s *memScreen) Init() error      { return nil }
+func (s *memScreen) Close()           {}
+func (s *memScreen) Size() (int, int) { return s.width, s.height }
+func (s *memScreen) Flush() error     { return nil }
+func (s *memScreen) Interrupt()       {}
+
+func (s *memScreen) Clear(fg, bg termbox.Attribute) error {
+	for i := range s.cells {
+		s.cells[i] = termbox.Cell{Ch: ' ', Fg: fg, Bg: bg}
+	}
+	return nil
+}
+
+func (s *memScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if x < 0 || x >= s.width || y < 0 || y >= s.height {
+		return
+	}
+	s.cells[y*s.width+x] = termbox.Cell{Ch: ch, Fg: fg, Bg: bg}
+}
+
+func (s *memScreen) SetCursor(x, y int) {
+	s.cursorX, s.cursorY = x, y
+}
+
+func (s *memScreen) PollEvent() termbox.Event {
+	if len(s.events) == 0 {
+		return termbox.Event{Type: termbox.EventError, Err: io.EOF}
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	if ev.Type == termbox.EventResize {
+		s.resize(ev.Width, ev.Height)
+	}
+	if s.onPoll != nil {
+		s.onPoll(ev)
+	}
+	return ev
+}
+
+func (s *memScreen) SetInputMode(mode termbox.InputMode) termbox.InputMode {
+	if mode != termbox.InputCurrent {
+		s.inputMode = mode
+	}
+	return s.inputMode
+}
+
+func (s *memScreen) SetOutputMode(mode termbox.OutputMode) termbox.OutputMode {
+	if mode != termbox.OutputCurrent {
+		s.outputMode = mode
+	}
+	return s.outputMode
+}
+
+// Cell returns the cell at (x, y)
+func (s *memScreen) Cell(x, y int) termbox.Cell {
+	return s.cells[y*s.width+x]
+}
+
+// Line returns the text of the line "y" without trailing spaces
+func (s *memScreen) Line(y int) string {
+	var line []rune
+	for x := 0; x < s.width; x++ {
+		line = append(line, s.Cell(x, y).Ch)
+	}
+	return strings.TrimRight(string(line), " ")
+}
+
+// Lines returns the text of all the lines of the screen
+func (s *memScreen) Lines() []string {
+	var lines []string
+	for y := 0; y < s.height; y++ {
+		lines = append(lines, s.Line(y))
+	}
+	return lines
+}
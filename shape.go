@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+// shapeView is the shape summary of a node: a line per key path with the
+// types, the number of values and examples seen at it
+type shapeView struct {
+	lines []string
+	// paths are the paths relative to the node of the lines, nil for the header
+	paths [][]query.Token
+}
+
+// shapeRow is a key path of a shape and what was seen at it
+type shapeRow struct {
+	path   []query.Token
+	types  string
+	seen   string
+	values string
+}
+
+// shapeRows lists the shape and all the shapes nested in it. Keys of objects
+// are counted against the objects, elements of arrays against all the arrays
+func shapeRows(s *Shape) []shapeRow {
+	rows := []shapeRow{newShapeRow(nil, s, strconv.Itoa(s.Count))}
+	var walk func(s *Shape, path []query.Token)
+	walk = func(s *Shape, path []query.Token) {
+		for _, f := range s.Fields {
+			p := append(append([]query.Token{}, path...), query.Key(f.Name))
+			rows = append(rows, newShapeRow(p, f.Shape, fmt.Sprintf("%d/%d", f.Count, s.Objects)))
+			walk(f.Shape, p)
+		}
+		if s.Elem != nil {
+			p := append(append([]query.Token{}, path...), query.ArrayAsterisk)
+			rows = append(rows, newShapeRow(p, s.Elem, strconv.Itoa(s.Elem.Count)))
+			walk(s.Elem, p)
+		}
+	}
+	walk(s, []query.Token{})
+	return rows
+}
+
+func newShapeRow(path []query.Token, s *Shape, seen string) shapeRow {
+	values := strings.Join(s.Examples, ", ")
+	if s.Min != "" {
+		values += fmt.Sprintf("  [%s..%s]", s.Min, s.Max)
+	}
+	return shapeRow{
+		path:   path,
+		types:  strings.Join(s.Kinds.Names(), "|"),
+		seen:   seen,
+		values: strings.TrimSpace(values),
+	}
+}
+
+// newShapeView infers the shape of the node and lays the rows out in columns
+func newShapeView(node interface{}, sep rune) *shapeView {
+	rows := shapeRows(inferShape(node))
+	names := make([]string, len(rows))
+	header := shapeRow{types: "TYPES", seen: "SEEN", values: "VALUES"}
+	widths := []int{runewidth.StringWidth("PATH"), runewidth.StringWidth(header.types), runewidth.StringWidth(header.seen)}
+	for i, r := range rows {
+		names[i] = query.Format(r.path, sep)
+		if len(r.path) == 0 {
+			names[i] = "(root)"
+		}
+		for j, col := range []string{names[i], r.types, r.seen} {
+			if w := runewidth.StringWidth(col); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+	line := func(name string, r shapeRow) string {
+		return strings.TrimRight(fmt.Sprintf("%s  %s  %s  %s",
+			runewidth.FillRight(name, widths[0]),
+			runewidth.FillRight(r.types, widths[1]),
+			runewidth.FillLeft(r.seen, widths[2]),
+			r.values), " ")
+	}
+	v := &shapeView{lines: []string{line("PATH", header)}, paths: [][]query.Token{nil}}
+	for i, r := range rows {
+		v.lines = append(v.lines, line(names[i], r))
+		v.paths = append(v.paths, r.path)
+	}
+	return v
+}
+
+// shape returns the shape summary of the displayed node. It is computed once per node path
+func (e *Explorer) shape() *shapeView {
+	key := query.Format(e.docPath, e.query.Sep)
+	if v, ok := e.shapes[key]; ok {
+		return v
+	}
+	if e.shapes == nil {
+		e.shapes = map[string]*shapeView{}
+	}
+	v := newShapeView(e.display.Doc.Interface(), e.query.Sep)
+	e.shapes[key] = v
+	return v
+}
+
+func (e *Explorer) displayShape() {
+	v := e.shape()
+	e.display.DocHeight = len(v.lines)
+	e.display.Clamp(e.layout.ContentHeight)
+	for i := 0; i < e.layout.ContentHeight && e.display.DocOffsetY+i < len(v.lines); i++ {
+		line := v.lines[e.display.DocOffsetY+i]
+		fg := termbox.ColorDefault
+		if v.paths[e.display.DocOffsetY+i] == nil {
+			fg = e.theme.Attr(e.theme.Field)
+		}
+		e.drawString(e.layout.ContentY+i, line, fg, termbox.ColorDefault)
+	}
+}
+
+func (e *Explorer) toggleShape() {
+	e.display.ActiveCompletion = -1
+	e.display.Shape = !e.display.Shape
+	e.display.OnlyKeys = false
+	e.drawContents(true)
+}
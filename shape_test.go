@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+func TestNewShapeView(t *testing.T) {
+	v := newShapeView(decode(t, `{
+		"users": [
+			{"id": 1, "email": "a@x.io", "tags": ["x"]},
+			{"id": 7, "email": null},
+			{"id": 3, "tags": ["y", "x"]}
+		],
+		"a.b": true
+	}`), '.')
+	assert.Equal(t, []string{
+		"PATH              TYPES        SEEN  VALUES",
+		"(root)            object          1",
+		`["a.b"]           boolean       1/1  true`,
+		"users             array         1/1",
+		"users[*]          object          3",
+		`users[*].email    string|null   2/3  "a@x.io", null`,
+		"users[*].id       integer       3/3  1, 7, 3  [1..7]",
+		"users[*].tags     array         2/3",
+		`users[*].tags[*]  string          3  "x", "y"`,
+	}, v.lines)
+	assert.Nil(t, v.paths[0])
+	assert.Equal(t, []query.Token{query.Key("users"), query.ArrayAsterisk, query.Key("id")}, v.paths[6])
+}
+
+func TestExplorer_shape(t *testing.T) {
+	doc := `{"items": [{"n": 1}, {"n": 2.5, "s": "x"}]}`
+	e, scr, _, _ := runScript(t, doc, "items", termbox.KeyCtrlX)
+	assert.Equal(t, []string{
+		"PATH    TYPES           SEEN  VALUES",
+		"(root)  array              1",
+		"[*]     object             2",
+		"[*].n   integer|number   2/2  1, 2.5  [1",
+		"[*].s   string           1/2  \"x\"",
+	}, scr.Lines()[2:7])
+	assert.Contains(t, scr.Line(11), "shape")
+	assert.Len(t, e.shapes, 1)
+	assert.Contains(t, e.shapes, "items")
+
+	// clicking a line queries its path, keys-only mode turns the shape off
+	click := termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseLeft, MouseX: 1, MouseY: 5}
+	e, _, _, _ = runScript(t, doc, "items", termbox.KeyCtrlX, click)
+	assert.Equal(t, "items[*].n", e.query.Raw())
+	e, _, _, _ = runScript(t, doc, termbox.KeyCtrlX, termbox.KeyCtrlL)
+	assert.False(t, e.display.Shape)
+	assert.True(t, e.display.OnlyKeys)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	simplejson "github.com/bitly/go-simplejson"
+	runewidth "github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/qoops-1/vuje/query"
+)
+
+func (e *Explorer) drawStatusBar() {
+	if e.layout.StatusY == -1 {
+		return
+	}
+	left := docSummary(e.display.Doc, e.displaySize())
+	right := e.position()
+	msg := e.message
+	if msg == "" {
+		msg = e.queryErr
+	}
+	if msg == "" && e.follow != nil {
+		arr, _ := e.doc.Interface().([]interface{})
+		msg = e.follow.status(len(arr))
+	}
+	if msg == "" && e.other != nil {
+		msg = diffSummary(e.diff().changes)
+	}
+	if msg == "" && len(e.violations) > 0 {
+		msg = plural(len(e.violations), "schema violation") + ", F8 to jump"
+	}
+	// the path goes first when there is room for it next to the message, long paths are cut
+	rightWidth := runewidth.StringWidth(right) + 1
+	if e.display.Doc != nil {
+		room := e.layout.Width - rightWidth - 1 - runewidth.StringWidth(" "+left+" · ")
+		if msg != "" {
+			room -= runewidth.StringWidth(" " + msg + " ")
+		}
+		if path := shortenPath(e.nodePath(), room); path != "" {
+			left = path + " · " + left
+		}
+	}
+	statusFg := termbox.ColorDefault | termbox.AttrReverse
+	var cells []termbox.Cell
+	for _, ch := range " " + left + " " {
+		cells = append(cells, termbox.Cell{Ch: ch, Fg: statusFg})
+	}
+	if msg != "" {
+		for _, ch := range " " + msg + " " {
+			cells = append(cells, termbox.Cell{Ch: ch, Fg: e.theme.Attr(e.theme.Error) | termbox.AttrReverse})
+		}
+	}
+	// the position is always visible, the summary and the message are cut if they don't fit
+	if len(cells) > e.layout.Width-rightWidth-1 && e.layout.Width-rightWidth-1 >= 0 {
+		cells = cells[:e.layout.Width-rightWidth-1]
+	}
+	for len(cells) < e.layout.Width-rightWidth {
+		cells = append(cells, termbox.Cell{Ch: ' ', Fg: statusFg})
+	}
+	for _, ch := range right + " " {
+		cells = append(cells, termbox.Cell{Ch: ch, Fg: statusFg})
+	}
+	e.clearLine(e.layout.StatusY)
+	e.drawLine(e.layout.StatusY, cells)
+}
+
+// position describes the display mode and the part of the document visible in the window
+func (e *Explorer) position() string {
+	mode := "json"
+	if e.display.OnlyKeys {
+		mode = "keys"
+	}
+	if e.display.Shape {
+		mode = "shape"
+	}
+	if e.other != nil {
+		mode = "diff"
+	}
+	if e.decode {
+		mode += " decoded"
+	}
+	if e.redactor != nil && e.reveal {
+		mode += " revealed"
+	}
+	if e.dirty() {
+		mode += " [+]"
+	}
+	if e.help != nil {
+		return e.help.name
+	}
+	if e.display.Doc == nil || e.display.DocHeight == 0 {
+		return mode
+	}
+	bottom := e.display.DocOffsetY + e.layout.ContentHeight
+	if bottom > e.display.DocHeight {
+		bottom = e.display.DocHeight
+	}
+	return fmt.Sprintf("%s  %d/%d %d%%", mode, e.display.DocOffsetY+1, e.display.DocHeight, bottom*100/e.display.DocHeight)
+}
+
+// nodePath returns the path of the displayed node, the root is "(root)"
+func (e *Explorer) nodePath() string {
+	if path := query.Format(e.docPath, e.query.Sep); path != "" {
+		return path
+	}
+	return "(root)"
+}
+
+// minPathWidth is the narrowest cut path shown in the status bar
+const minPathWidth = 8
+
+// shortenPath cuts the beginning of the path to fit the width, the end of the path tells
+// more about the node. It returns "" if there is no room for the path
+func shortenPath(path string, width int) string {
+	if runewidth.StringWidth(path) <= width {
+		return path
+	}
+	if width < minPathWidth {
+		return ""
+	}
+	runes := []rune(path)
+	for len(runes) > 0 && runewidth.StringWidth(string(runes))+1 > width {
+		runes = runes[1:]
+	}
+	return "…" + string(runes)
+}
+
+// displaySize returns the size of the serialized displayed node. It is computed once per
+// node, the status bar is drawn after every key press
+func (e *Explorer) displaySize() int {
+	if e.sized != e.display.Doc {
+		e.sized = e.display.Doc
+		e.size = encodedSize(e.display.Doc)
+	}
+	return e.size
+}
+
+// encodedSize returns the size of the serialized document, or -1 if it cannot be serialized
+func encodedSize(doc *simplejson.Json) int {
+	if doc == nil {
+		return -1
+	}
+	encoded, err := doc.Encode()
+	if err != nil {
+		return -1
+	}
+	return len(encoded)
+}
+
+// docSummary returns the type, the number of elements and the size of the serialized document
+func docSummary(doc *simplejson.Json, size int) string {
+	if doc == nil {
+		return "no results"
+	}
+	parts := []string{query.TypeName(doc.Interface())}
+	switch t := doc.Interface().(type) {
+	case map[string]interface{}:
+		parts = append(parts, plural(len(t), "key"))
+	case []interface{}:
+		parts = append(parts, plural(len(t), "element"))
+	}
+	if size >= 0 {
+		parts = append(parts, humanSize(size))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func humanSize(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / unit
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		if size < unit {
+			return fmt.Sprintf("%.1f %s", size, suffix)
+		}
+		size /= unit
+	}
+	return fmt.Sprintf("%.1f TiB", size)
+}
+
+// queryError describes the syntax error of the query or the reason it has no results.
+// match is how the parsed query matched the document
+func queryError(q *Query, doc interface{}, match query.Match) string {
+	if q.LastEscape {
+		return ""
+	}
+	toks, err := query.Compile(q.Raw(), q.Sep)
+	if se, ok := err.(*query.SyntaxError); ok {
+		return fmt.Sprintf("column %d: %s", se.Pos+1, se.Msg)
+	}
+	if match == query.NoMatch {
+		if _, err = query.Get(doc, toks); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/qoops-1/vuje/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocSummary(t *testing.T) {
+	tbl := []struct {
+		doc     string
+		summary string
+	}{
+		{doc: `{"a": 1, "b": 2}`, summary: "object · 2 keys · 13 B"},
+		{doc: `[1]`, summary: "array · 1 element · 3 B"},
+		{doc: `"str"`, summary: "string · 5 B"},
+		{doc: `1.5`, summary: "number · 3 B"},
+		{doc: `true`, summary: "boolean · 4 B"},
+		{doc: `null`, summary: "null · 4 B"},
+	}
+	for _, tt := range tbl {
+		doc, err := simplejson.NewFromReader(strings.NewReader(tt.doc))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.summary, docSummary(doc, encodedSize(doc)))
+	}
+	assert.Equal(t, "no results", docSummary(nil, encodedSize(nil)))
+}
+
+func TestHumanSize(t *testing.T) {
+	assert.Equal(t, "1023 B", humanSize(1023))
+	assert.Equal(t, "1.0 KiB", humanSize(1024))
+	assert.Equal(t, "1.5 MiB", humanSize(3*1024*1024/2))
+}
+
+func TestQueryError(t *testing.T) {
+	doc := map[string]interface{}{"a": []interface{}{1}}
+	tbl := []struct {
+		raw string
+		msg string
+	}{
+		{raw: "a[0]", msg: ""},
+		{raw: "b", msg: ""},
+		{raw: `a\`, msg: ""},
+		{raw: "a[x", msg: "column 2: expected index in square brackets"},
+		{raw: "a[0]b", msg: `column 5: expected "." or "[" after index`},
+		{raw: "a[1]", msg: `path not found: no index [1] in "a"`},
+	}
+	for _, tt := range tbl {
+		q := &Query{Sep: '.'}
+		q.SetRaw(tt.raw)
+		_, match := query.Eval(doc, q.Parsed)
+		assert.Equal(t, tt.msg, queryError(q, doc, match), tt.raw)
+	}
+}
+
+func TestExplorer_nodePath(t *testing.T) {
+	e, err := NewExplorer(strings.NewReader(`{"users": [{"id": 1}]}`), '.', nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "(root)", e.nodePath())
+	e.docPath = []query.Token{query.Key("users"), query.Index(0)}
+	assert.Equal(t, "users[0]", e.nodePath())
+
+	// the size is computed once per displayed node
+	e.display.Doc = e.doc
+	assert.Equal(t, 20, e.displaySize())
+	e.size = 1
+	assert.Equal(t, 1, e.displaySize())
+	e.display.Doc = e.doc.Get("users").GetIndex(0)
+	assert.Equal(t, 8, e.displaySize())
+}
+
+func TestShortenPath(t *testing.T) {
+	assert.Equal(t, "users[0].id", shortenPath("users[0].id", 11))
+	assert.Equal(t, "…s[0].id", shortenPath("users[0].id", 8))
+	assert.Equal(t, "", shortenPath("users[0].id", 7))
+}
+
+func TestExplorer_statusPath(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"users": [{"id": 1}]}`), '.', theme)
+	assert.NoError(t, err)
+	scr := newMemScreen(60, 12, script("users[0]")...)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, " users[0] · object · 1 key · 8 B", strings.TrimRight(scr.Line(11)[:40], " "))
+
+	// the path gives way to the summary on narrow terminals
+	_, scr, _, _ = runScript(t, `{"users": [{"id": 1}]}`)
+	assert.Equal(t, " object · 1 key · 20 B   json  1/7 100%", scr.Line(11))
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/nwidger/jsoncolor"
+)
+
+// ColorMode is the richest palette the terminal is able to render
+type ColorMode int
+
+const (
+	ColorModeNone ColorMode = iota
+	ColorMode8
+	ColorMode256
+	ColorModeTrue
+)
+
+// Color is a theme color. RGB is used on 256-color and truecolor terminals,
+// Basic is the fallback for terminals with only 8 colors
+type Color struct {
+	RGB   uint32
+	Basic termbox.Attribute
+}
+
+// Style describes how a single kind of text is rendered. Nil Fg stands for
+// the default terminal color
+type Style struct {
+	Fg   *Color
+	Bold bool
+}
+
+// Theme is a set of styles for every element of the interface and the color
+// mode they are rendered in
+type Theme struct {
+	Name        string
+	Mode        ColorMode
+	Punctuation Style
+	Field       Style
+	String      Style
+	Bool        Style
+	Number      Style
+	Null        Style
+	Query       Style
+	Hint        Style
+	Selected    Style
+	Error       Style
+	// Added and Removed color the changed lines in diff mode
+	Added   Style
+	Removed Style
+}
+
+func rgb(c uint32, basic termbox.Attribute) *Color {
+	return &Color{RGB: c, Basic: basic}
+}
+
+var themes = map[string]Theme{
+	"dark": {
+		Punctuation: Style{Bold: true},
+		Field:       Style{Fg: rgb(0x5fafff, termbox.ColorBlue), Bold: true},
+		String:      Style{Fg: rgb(0x87d787, termbox.ColorGreen)},
+		Bool:        Style{Fg: rgb(0xffaf5f, termbox.ColorYellow)},
+		Number:      Style{Fg: rgb(0xd7afff, termbox.ColorMagenta)},
+		Null:        Style{Fg: rgb(0x8a8a8a, termbox.ColorWhite)},
+		Query:       Style{Fg: rgb(0x5fafff, termbox.ColorBlue)},
+		Hint:        Style{Fg: rgb(0x87d787, termbox.ColorGreen)},
+		Selected:    Style{Fg: rgb(0xffffff, termbox.ColorWhite), Bold: true},
+		Error:       Style{Fg: rgb(0xff5f5f, termbox.ColorRed)},
+		Added:       Style{Fg: rgb(0x87d787, termbox.ColorGreen)},
+		Removed:     Style{Fg: rgb(0xff5f5f, termbox.ColorRed)},
+	},
+	"light": {
+		Punctuation: Style{Bold: true},
+		Field:       Style{Fg: rgb(0x005fd7, termbox.ColorBlue), Bold: true},
+		String:      Style{Fg: rgb(0x008700, termbox.ColorGreen)},
+		Bool:        Style{Fg: rgb(0xaf5f00, termbox.ColorYellow)},
+		Number:      Style{Fg: rgb(0x8700af, termbox.ColorMagenta)},
+		Null:        Style{Fg: rgb(0x6c6c6c, termbox.ColorBlack)},
+		Query:       Style{Fg: rgb(0x005fd7, termbox.ColorBlue)},
+		Hint:        Style{Fg: rgb(0x008700, termbox.ColorGreen)},
+		Selected:    Style{Fg: rgb(0x000000, termbox.ColorBlack), Bold: true},
+		Error:       Style{Fg: rgb(0xd70000, termbox.ColorRed)},
+		Added:       Style{Fg: rgb(0x008700, termbox.ColorGreen)},
+		Removed:     Style{Fg: rgb(0xd70000, termbox.ColorRed)},
+	},
+	"none": {
+		Field:    Style{Bold: true},
+		Selected: Style{Bold: true},
+	},
+}
+
+// ThemeNames returns the names of all the built-in themes
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns a copy of a built-in theme rendered in the given mode.
+// The "none" theme is always rendered without colors
+func LookupTheme(name string, mode ColorMode) (*Theme, error) {
+	t, ok := themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q, available themes: %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	t.Name = name
+	t.Mode = mode
+	if name == "none" {
+		t.Mode = ColorModeNone
+	}
+	return &t, nil
+}
+
+// DetectColorMode guesses the color capabilities of the terminal from the environment.
+// NO_COLOR (https://no-color.org) disables colors completely
+func DetectColorMode(getenv func(string) string) ColorMode {
+	if getenv("NO_COLOR") != "" {
+		return ColorModeNone
+	}
+	colorterm := strings.ToLower(getenv("COLORTERM"))
+	if colorterm == "truecolor" || colorterm == "24bit" {
+		return ColorModeTrue
+	}
+	term := getenv("TERM")
+	if term == "dumb" {
+		return ColorModeNone
+	}
+	if strings.Contains(term, "256color") {
+		return ColorMode256
+	}
+	return ColorMode8
+}
+
+// OutputMode returns the termbox output mode needed to render the theme.
+// Termbox has no truecolor output, so RGB colors are approximated with the 256-color palette
+func (t *Theme) OutputMode() termbox.OutputMode {
+	if t.Mode >= ColorMode256 {
+		return termbox.Output256
+	}
+	return termbox.OutputNormal
+}
+
+// Attr converts a style into a termbox foreground attribute
+func (t *Theme) Attr(s Style) termbox.Attribute {
+	attr := termbox.ColorDefault
+	if s.Fg != nil {
+		switch t.Mode {
+		case ColorMode8:
+			attr = s.Fg.Basic
+		case ColorMode256, ColorModeTrue:
+			// termbox color attributes are 1-based, 0 is the default color
+			attr = termbox.Attribute(xterm256(s.Fg.RGB) + 1)
+		}
+	}
+	if s.Bold {
+		attr |= termbox.AttrBold
+	}
+	return attr
+}
+
+// ANSI returns a jsoncolor printer that wraps text in the escape sequences of the style
+func (t *Theme) ANSI(s Style) jsoncolor.SprintfFuncer {
+	var params []string
+	if s.Bold {
+		params = append(params, "1")
+	}
+	if s.Fg != nil {
+		switch t.Mode {
+		case ColorMode8:
+			params = append(params, fmt.Sprint(30+int(s.Fg.Basic)-1))
+		case ColorMode256:
+			params = append(params, fmt.Sprintf("38;5;%d", xterm256(s.Fg.RGB)))
+		case ColorModeTrue:
+			params = append(params, fmt.Sprintf("38;2;%d;%d;%d", s.Fg.RGB>>16, (s.Fg.RGB>>8)&0xff, s.Fg.RGB&0xff))
+		}
+	}
+	if t.Mode == ColorModeNone || len(params) == 0 {
+		return ansiStyle{}
+	}
+	return ansiStyle{start: "\x1b[" + strings.Join(params, ";") + "m", end: "\x1b[0m"}
+}
+
+type ansiStyle struct {
+	start, end string
+}
+
+func (as ansiStyle) SprintfFunc() func(format string, a ...interface{}) string {
+	return func(format string, a ...interface{}) string {
+		return as.start + fmt.Sprintf(format, a...) + as.end
+	}
+}
+
+// applyTheme sets the colors of all JSON tokens of the formatter from the theme
+func applyTheme(f *jsoncolor.Formatter, style func(Style) jsoncolor.SprintfFuncer, t *Theme) {
+	f.SpaceColor = style(Style{})
+	f.CommaColor = style(t.Punctuation)
+	f.ColonColor = style(t.Punctuation)
+	f.ObjectColor = style(t.Punctuation)
+	f.ArrayColor = style(t.Punctuation)
+	f.FieldQuoteColor = style(t.Field)
+	f.FieldColor = style(t.Field)
+	f.StringQuoteColor = style(t.String)
+	f.StringColor = style(t.String)
+	f.TrueColor = style(t.Bool)
+	f.FalseColor = style(t.Bool)
+	f.NumberColor = style(t.Number)
+	f.NullColor = style(t.Null)
+}
+
+// xterm256 returns the index of the closest color in the xterm 256-color palette,
+// searching the 6x6x6 color cube and the grayscale ramp
+func xterm256(c uint32) int {
+	r, g, b := int(c>>16), int((c>>8)&0xff), int(c&0xff)
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v int) int {
+		best := 0
+		for i, l := range levels {
+			if abs(v-l) < abs(v-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := nearest(r), nearest(g), nearest(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := sqDist(r, g, b, levels[ri], levels[gi], levels[bi])
+
+	avg := (r + g + b) / 3
+	grayIdx := (avg - 3) / 10
+	if grayIdx < 0 {
+		grayIdx = 0
+	} else if grayIdx > 23 {
+		grayIdx = 23
+	}
+	grayLvl := 8 + 10*grayIdx
+	if sqDist(r, g, b, grayLvl, grayLvl, grayLvl) < cubeDist {
+		return 232 + grayIdx
+	}
+	return cube
+}
+
+func sqDist(r1, g1, b1, r2, g2, b2 int) int {
+	return (r1-r2)*(r1-r2) + (g1-g2)*(g1-g2) + (b1-b2)*(b1-b2)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func detectTheme(name string) (*Theme, error) {
+	return LookupTheme(name, DetectColorMode(os.Getenv))
+}
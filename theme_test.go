@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectColorMode(t *testing.T) {
+	tbl := []struct {
+		env  map[string]string
+		mode ColorMode
+	}{
+		{env: map[string]string{"TERM": "xterm"}, mode: ColorMode8},
+		{env: map[string]string{"TERM": "xterm-256color"}, mode: ColorMode256},
+		{env: map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, mode: ColorModeTrue},
+		{env: map[string]string{"TERM": "xterm", "COLORTERM": "24bit"}, mode: ColorModeTrue},
+		{env: map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, mode: ColorModeNone},
+		{env: map[string]string{"TERM": "dumb"}, mode: ColorModeNone},
+	}
+	for _, tt := range tbl {
+		getenv := func(k string) string { return tt.env[k] }
+		assert.Equal(t, tt.mode, DetectColorMode(getenv), "%v", tt.env)
+	}
+}
+
+func TestXterm256(t *testing.T) {
+	assert.Equal(t, 16, xterm256(0x000000))
+	assert.Equal(t, 231, xterm256(0xffffff))
+	assert.Equal(t, 75, xterm256(0x5fafff))
+	assert.Equal(t, 196, xterm256(0xff0000))
+	assert.Equal(t, 245, xterm256(0x8a8a8a))
+}
+
+func TestTheme_Attr(t *testing.T) {
+	style := Style{Fg: rgb(0xff0000, termbox.ColorRed), Bold: true}
+
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	assert.Equal(t, termbox.ColorRed|termbox.AttrBold, theme.Attr(style))
+
+	theme, _ = LookupTheme("dark", ColorMode256)
+	assert.Equal(t, termbox.Attribute(197)|termbox.AttrBold, theme.Attr(style))
+
+	theme, _ = LookupTheme("none", ColorModeTrue)
+	assert.Equal(t, ColorModeNone, theme.Mode)
+	assert.Equal(t, termbox.ColorDefault|termbox.AttrBold, theme.Attr(style))
+
+	_, err = LookupTheme("unknown", ColorMode8)
+	assert.Error(t, err)
+}
+
+func TestTheme_ANSI(t *testing.T) {
+	style := Style{Fg: rgb(0x5fafff, termbox.ColorBlue), Bold: true}
+	tbl := []struct {
+		mode ColorMode
+		out  string
+	}{
+		{mode: ColorModeNone, out: "null"},
+		{mode: ColorMode8, out: "\x1b[1;34mnull\x1b[0m"},
+		{mode: ColorMode256, out: "\x1b[1;38;5;75mnull\x1b[0m"},
+		{mode: ColorModeTrue, out: "\x1b[1;38;2;95;175;255mnull\x1b[0m"},
+	}
+	for _, tt := range tbl {
+		theme := &Theme{Mode: tt.mode}
+		assert.Equal(t, tt.out, theme.ANSI(style).SprintfFunc()("%s", "null"))
+	}
+}
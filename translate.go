@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+// translators convert paths into the query languages of other tools
+var translators = []struct {
+	name      string
+	flag      string
+	translate func([]query.Token) (string, error)
+}{
+	{name: "jq", flag: "jq", translate: query.ToJq},
+	{name: "JSONPath", flag: "jsonpath", translate: query.ToJSONPath},
+	{name: "JSON Pointer", flag: "pointer", translate: query.ToPointer},
+}
+
+// translateQuery translates the raw query into the language named by the -to option.
+// "vuje" converts JSON Pointers and JSONPath expressions into vuje queries. Numeric steps
+// of pointers are keys where the document has objects, without a document they are indices
+func translateQuery(raw string, sep rune, to string, doc interface{}) (string, error) {
+	path, err := compileQuery(raw, sep, doc)
+	if err != nil {
+		return "", classifyQueryError(err)
+	}
+	if to == "vuje" {
+		return query.Format(path, sep), nil
+	}
+	names := []string{"vuje"}
+	for _, t := range translators {
+		if t.flag == to {
+			translated, err := t.translate(path)
+			if err != nil {
+				return "", &Error{Kind: UsageError, Err: err}
+			}
+			return translated, nil
+		}
+		names = append(names, t.flag)
+	}
+	return "", usageErrorf("unknown query language %q, available languages: %s", to, strings.Join(names, ", "))
+}
+
+// translationLines shows the raw query in every supported query language
+func translationLines(raw string, sep rune) []string {
+	lines := []string{"QUERY", "", "  " + raw}
+	path, compileErr := query.Compile(raw, sep)
+	for _, t := range translators {
+		var translated string
+		err := compileErr
+		if err == nil {
+			translated, err = t.translate(path)
+		}
+		if err != nil {
+			translated = err.Error()
+		}
+		lines = append(lines, "", t.name, "", "  "+translated)
+	}
+	return append(lines, "", "Press Esc or q to close")
+}
+
+// translate converts a JSON Pointer or JSONPath in the query line into a vuje query.
+// Otherwise it shows the query translated into jq, JSONPath and JSON Pointer
+func (e *Explorer) translate() {
+	raw := e.query.Raw()
+	if !query.IsPointer(raw) && !query.IsJSONPath(raw) {
+		e.help = &helpView{name: "translate", lines: translationLines(raw, e.query.Sep)}
+		e.help.view.DocHeight = len(e.help.lines)
+		e.fullRedraw()
+		return
+	}
+	path, err := compileQuery(raw, e.query.Sep, e.queried())
+	if err != nil {
+		e.message = err.Error()
+		if se, ok := err.(*query.SyntaxError); ok {
+			e.message = fmt.Sprintf("column %d: %s", se.Pos+1, se.Msg)
+		}
+		return
+	}
+	e.display.ActiveCompletion = -1
+	e.query.SetRaw(e.query.Format(path))
+	e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+	e.syncWithQuery()
+}
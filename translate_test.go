@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateQuery(t *testing.T) {
+	tbl := []struct {
+		query, to, res string
+	}{
+		{query: "items[*].id", to: "jq", res: "[.items[] | .id]"},
+		{query: `a["b.c"][0]`, to: "jsonpath", res: "$.a['b.c'][0]"},
+		{query: "a/b", to: "pointer", res: "/a~1b"},
+		{query: "/a/0/b~1c", to: "vuje", res: "a[0].b/c"},
+		{query: "/~1a/$.b", to: "vuje", res: `["/a"]["$.b"]`},
+		{query: "$.a['x y'][*]", to: "vuje", res: "a.x y[*]"},
+		{query: `["a b"].x`, to: "vuje", res: "a b.x"},
+	}
+	for _, tt := range tbl {
+		res, err := translateQuery(tt.query, '.', tt.to, nil)
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.res, res, tt.query)
+	}
+
+	_, err := translateQuery("a", '.', "xpath", nil)
+	assert.EqualError(t, err, `unknown query language "xpath", available languages: vuje, jq, jsonpath, pointer`)
+	_, err = translateQuery("a|length", '.', "pointer", nil)
+	assert.Equal(t, UsageError, err.(*Error).Kind)
+	_, err = translateQuery("$..a", '.', "jq", nil)
+	assert.Equal(t, 2, err.(*Error).Column)
+
+	// numeric steps are keys of the objects of the document
+	res, err := translateQuery("/a/0/1", '.', "vuje", map[string]interface{}{"a": map[string]interface{}{"0": []interface{}{1, 2}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "a.0[1]", res)
+	res, err = translateQuery("/a/0/1", '.', "vuje", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a[0][1]", res)
+}
+
+func TestExecuteQuery_foreign(t *testing.T) {
+	e, err := NewExplorer(strings.NewReader(`{"a": [{"b": 1}], "$c": 2}`), '.', &Theme{})
+	assert.NoError(t, err)
+	res, err := e.ExecuteQuery("/a/0/b")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.MustInt())
+	res, err = e.ExecuteQuery("$.a[0].b")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.MustInt())
+	// keys starting with "$" are still vuje queries
+	res, err = e.ExecuteQuery("$c")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.MustInt())
+}
+
+func TestExplorer_translate(t *testing.T) {
+	doc := `{"items": [{"id": 1}]}`
+	_, scr, _, _ := runScript(t, doc, "items[0].id", termbox.KeyCtrlY)
+	assert.Equal(t, []string{"QUERY", "", "  items[0].id", "", "jq", "", "  .items[0].id", "", "JSONPath"}, scr.Lines()[2:11])
+	assert.Contains(t, scr.Line(11), "translate")
+
+	// the query is kept when the translations are closed
+	e, scr, _, _ := runScript(t, doc, "items[0].id", termbox.KeyCtrlY, termbox.KeyEsc)
+	assert.Equal(t, "items[0].id", e.query.Raw())
+	assert.Equal(t, " 1", scr.Line(2))
+
+	e, _, _, _ = runScript(t, doc, "/items/0", termbox.KeyCtrlY)
+	assert.Equal(t, "items[0]", e.query.Raw())
+	assert.Equal(t, 8, e.query.QueryPos)
+
+	_, scr, _, _ = runScript(t, doc, "$..id", termbox.KeyCtrlY)
+	assert.Contains(t, scr.Line(11), "column 2: recursive")
+}
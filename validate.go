@@ -0,0 +1,629 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+// Violation is a place in the document that does not match the schema
+type Violation struct {
+	// Path is the instance path of the value that failed
+	Path []query.Token
+	// Keyword is the schema keyword that failed, e.g. "required"
+	Keyword string
+	Msg     string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", pointerOf(v.Path), v.Keyword, v.Msg)
+}
+
+// pointerOf formats the path as a JSON Pointer, the root is "(root)"
+func pointerOf(path []query.Token) string {
+	ptr, err := query.ToPointer(path)
+	if err != nil {
+		return query.Format(path, '.')
+	}
+	if ptr == "" {
+		return "(root)"
+	}
+	return ptr
+}
+
+// Validator checks documents against a JSON Schema. Drafts 7 and 2020-12 are
+// supported, except for remote references, formats and unevaluated* keywords
+type Validator struct {
+	root interface{}
+	// draft7 switches items, additionalItems, dependencies and $ref to their draft 7 meaning
+	draft7  bool
+	regexps map[string]*regexp.Regexp
+}
+
+// maxRefDepth stops references that refer to themselves without descending into the document
+const maxRefDepth = 64
+
+// NewValidator checks that the decoded schema is an object or a boolean
+func NewValidator(schema interface{}) (*Validator, error) {
+	v := &Validator{root: schema, regexps: map[string]*regexp.Regexp{}}
+	switch t := schema.(type) {
+	case bool:
+	case map[string]interface{}:
+		if s, ok := t["$schema"].(string); ok {
+			v.draft7 = strings.Contains(s, "draft-07") || strings.Contains(s, "draft-06") || strings.Contains(s, "draft-04")
+		}
+	default:
+		return nil, fmt.Errorf("schema must be an object or a boolean, got %s", query.TypeName(schema))
+	}
+	return v, nil
+}
+
+// Validate returns the violations in the order of the document, keys of objects sorted
+func (v *Validator) Validate(doc interface{}) []Violation {
+	var vs []Violation
+	v.validate(v.root, doc, []query.Token{}, 0, &vs)
+	return vs
+}
+
+// itemsOnly are the keywords of array schemas that do not depend on the other elements
+var itemsOnly = map[string]bool{
+	"$schema": true, "$id": true, "$defs": true, "definitions": true, "$comment": true,
+	"title": true, "description": true, "type": true, "items": true,
+}
+
+// ValidateAppended returns the violations of the elements of the array from the index on,
+// as Validate would report them after the ones of the elements before it. It reports false
+// when the schema of the array depends on all of its elements and the whole array has to
+// be validated again, e.g. with "uniqueItems" or "maxItems"
+func (v *Validator) ValidateAppended(arr []interface{}, from int) ([]Violation, bool) {
+	s, ok := v.root.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	for k := range s {
+		if !itemsOnly[k] {
+			return nil, false
+		}
+	}
+	items, ok := s["items"]
+	if _, positional := items.([]interface{}); positional {
+		return nil, false
+	}
+	var vs []Violation
+	for i := from; i < len(arr) && ok; i++ {
+		v.validate(items, arr[i], []query.Token{query.Index(i)}, 0, &vs)
+	}
+	return vs, true
+}
+
+// valid reports whether the value matches the schema without collecting the violations
+func (v *Validator) valid(schema, doc interface{}, path []query.Token, depth int) bool {
+	var vs []Violation
+	v.validate(schema, doc, path, depth, &vs)
+	return len(vs) == 0
+}
+
+func (v *Validator) validate(schema, doc interface{}, path []query.Token, depth int, vs *[]Violation) {
+	fail := func(keyword, format string, args ...interface{}) {
+		*vs = append(*vs, Violation{Path: path, Keyword: keyword, Msg: fmt.Sprintf(format, args...)})
+	}
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if schema == false {
+			fail("false", "no value is allowed here")
+		}
+		return
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		switch {
+		case err != nil:
+			fail("$ref", "%s", err)
+		case depth >= maxRefDepth:
+			fail("$ref", "%q refers to itself", ref)
+		default:
+			v.validate(target, doc, path, depth+1, vs)
+		}
+		if v.draft7 {
+			return
+		}
+	}
+	v.validateType(s, doc, fail)
+	switch t := doc.(type) {
+	case json.Number, float64, int:
+		v.validateNumber(s, t, fail)
+	case string:
+		v.validateString(s, t, fail)
+	case []interface{}:
+		v.validateArray(s, t, path, depth, vs, fail)
+	case map[string]interface{}:
+		v.validateObject(s, t, path, depth, vs, fail)
+	}
+	v.validateCombinators(s, doc, path, depth, vs, fail)
+}
+
+func (v *Validator) validateType(s map[string]interface{}, doc interface{}, fail func(string, string, ...interface{})) {
+	if t, ok := s["type"]; ok {
+		types, _ := t.([]interface{})
+		if name, ok := t.(string); ok {
+			types = []interface{}{name}
+		}
+		matches := false
+		var names []string
+		for _, tt := range types {
+			name, _ := tt.(string)
+			names = append(names, name)
+			if name == query.TypeName(doc) || name == "integer" && isInteger(doc) {
+				matches = true
+			}
+		}
+		if !matches {
+			fail("type", "expected %s, got %s", strings.Join(names, " or "), query.TypeName(doc))
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if query.Equal(e, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "%s is not one of %s", encodeValue(doc), encodeValue(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !query.Equal(c, doc) {
+		fail("const", "expected %s, got %s", encodeValue(c), encodeValue(doc))
+	}
+}
+
+func (v *Validator) validateNumber(s map[string]interface{}, n interface{}, fail func(string, string, ...interface{})) {
+	r, ok := query.ToRat(n)
+	if !ok {
+		return
+	}
+	for _, b := range []struct {
+		keyword string
+		fails   func(cmp int) bool
+		msg     string
+	}{
+		{"minimum", func(cmp int) bool { return cmp < 0 }, "%s is less than %s"},
+		{"maximum", func(cmp int) bool { return cmp > 0 }, "%s is greater than %s"},
+		{"exclusiveMinimum", func(cmp int) bool { return cmp <= 0 }, "%s is not greater than %s"},
+		{"exclusiveMaximum", func(cmp int) bool { return cmp >= 0 }, "%s is not less than %s"},
+	} {
+		if bound, ok := query.ToRat(s[b.keyword]); ok && b.fails(r.Cmp(bound)) {
+			fail(b.keyword, b.msg, encodeValue(n), encodeValue(s[b.keyword]))
+		}
+	}
+	if m, ok := query.ToRat(s["multipleOf"]); ok && m.Sign() > 0 {
+		if !new(big.Rat).Quo(r, m).IsInt() {
+			fail("multipleOf", "%s is not a multiple of %s", encodeValue(n), encodeValue(s["multipleOf"]))
+		}
+	}
+}
+
+func (v *Validator) validateString(s map[string]interface{}, str string, fail func(string, string, ...interface{})) {
+	length := utf8.RuneCountInString(str)
+	if min, ok := toInt(s["minLength"]); ok && length < min {
+		fail("minLength", "length %d is less than %d", length, min)
+	}
+	if max, ok := toInt(s["maxLength"]); ok && length > max {
+		fail("maxLength", "length %d is greater than %d", length, max)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := v.regexp(pattern)
+		if err != nil {
+			fail("pattern", "%s", err)
+		} else if !re.MatchString(str) {
+			fail("pattern", "%s does not match %q", encodeValue(str), pattern)
+		}
+	}
+}
+
+func (v *Validator) validateArray(s map[string]interface{}, arr []interface{}, path []query.Token, depth int, vs *[]Violation, fail func(string, string, ...interface{})) {
+	elem := func(i int) []query.Token {
+		return append(append([]query.Token{}, path...), query.Index(i))
+	}
+	// prefix is the number of elements validated by positional schemas
+	prefix := 0
+	prefixItems, _ := s["prefixItems"].([]interface{})
+	if items, ok := s["items"].([]interface{}); ok && v.draft7 {
+		prefixItems = items
+	}
+	for i, schema := range prefixItems {
+		if i < len(arr) {
+			v.validate(schema, arr[i], elem(i), depth, vs)
+			prefix++
+		}
+	}
+	rest, hasRest := s["items"]
+	if _, positional := rest.([]interface{}); positional {
+		rest, hasRest = s["additionalItems"]
+		if !v.draft7 {
+			hasRest = false
+		}
+	}
+	if hasRest {
+		for i := prefix; i < len(arr); i++ {
+			v.validate(rest, arr[i], elem(i), depth, vs)
+		}
+	}
+	if contains, ok := s["contains"]; ok {
+		n := 0
+		for i, el := range arr {
+			if v.valid(contains, el, elem(i), depth) {
+				n++
+			}
+		}
+		min, hasMin := toInt(s["minContains"])
+		if !hasMin {
+			min = 1
+		}
+		if n < min {
+			fail("contains", "%d elements match the schema, at least %d required", n, min)
+		}
+		if max, ok := toInt(s["maxContains"]); ok && n > max {
+			fail("maxContains", "%d elements match the schema, at most %d allowed", n, max)
+		}
+	}
+	if min, ok := toInt(s["minItems"]); ok && len(arr) < min {
+		fail("minItems", "%d elements, at least %d required", len(arr), min)
+	}
+	if max, ok := toInt(s["maxItems"]); ok && len(arr) > max {
+		fail("maxItems", "%d elements, at most %d allowed", len(arr), max)
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if query.Equal(arr[i], arr[j]) {
+					fail("uniqueItems", "elements [%d] and [%d] are equal", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (v *Validator) validateObject(s map[string]interface{}, obj map[string]interface{}, path []query.Token, depth int, vs *[]Violation, fail func(string, string, ...interface{})) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	field := func(k string) []query.Token {
+		return append(append([]query.Token{}, path...), query.Key(k))
+	}
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, ok := obj[name]; !ok {
+					fail("required", "missing key %q", name)
+				}
+			}
+		}
+	}
+	props, _ := s["properties"].(map[string]interface{})
+	patterns, _ := s["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := s["additionalProperties"]
+	names, hasNames := s["propertyNames"]
+	for _, k := range keys {
+		if hasNames && !v.valid(names, k, field(k), depth) {
+			fail("propertyNames", "key %q does not match the schema of property names", k)
+		}
+		matched := false
+		if schema, ok := props[k]; ok {
+			v.validate(schema, obj[k], field(k), depth, vs)
+			matched = true
+		}
+		for pattern, schema := range patterns {
+			re, err := v.regexp(pattern)
+			if err != nil {
+				fail("patternProperties", "%s", err)
+				continue
+			}
+			if re.MatchString(k) {
+				v.validate(schema, obj[k], field(k), depth, vs)
+				matched = true
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				fail("additionalProperties", "key %q is not allowed", k)
+			} else {
+				v.validate(additional, obj[k], field(k), depth, vs)
+			}
+		}
+	}
+	if min, ok := toInt(s["minProperties"]); ok && len(obj) < min {
+		fail("minProperties", "%d keys, at least %d required", len(obj), min)
+	}
+	if max, ok := toInt(s["maxProperties"]); ok && len(obj) > max {
+		fail("maxProperties", "%d keys, at most %d allowed", len(obj), max)
+	}
+	deps := map[string]interface{}{}
+	for _, keyword := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		if d, ok := s[keyword].(map[string]interface{}); ok {
+			for k, dep := range d {
+				deps[k] = dep
+			}
+		}
+	}
+	for _, k := range keys {
+		switch dep := deps[k].(type) {
+		case nil:
+		case []interface{}:
+			for _, r := range dep {
+				if name, ok := r.(string); ok {
+					if _, ok := obj[name]; !ok {
+						fail("dependentRequired", "key %q requires key %q", k, name)
+					}
+				}
+			}
+		default:
+			v.validate(dep, obj, path, depth, vs)
+		}
+	}
+}
+
+func (v *Validator) validateCombinators(s map[string]interface{}, doc interface{}, path []query.Token, depth int, vs *[]Violation, fail func(string, string, ...interface{})) {
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, schema := range all {
+			v.validate(schema, doc, path, depth, vs)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matches := false
+		for _, schema := range anyOf {
+			if v.valid(schema, doc, path, depth) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			fail("anyOf", "value matches none of the %d schemas", len(anyOf))
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		var matched []string
+		for i, schema := range oneOf {
+			if v.valid(schema, doc, path, depth) {
+				matched = append(matched, strconv.Itoa(i))
+			}
+		}
+		switch len(matched) {
+		case 1:
+		case 0:
+			fail("oneOf", "value matches none of the %d schemas", len(oneOf))
+		default:
+			fail("oneOf", "value matches more than one schema: %s", strings.Join(matched, ", "))
+		}
+	}
+	if not, ok := s["not"]; ok && v.valid(not, doc, path, depth) {
+		fail("not", "value must not match the schema")
+	}
+	if cond, ok := s["if"]; ok {
+		branch := "else"
+		if v.valid(cond, doc, path, depth) {
+			branch = "then"
+		}
+		if schema, ok := s[branch]; ok {
+			v.validate(schema, doc, path, depth, vs)
+		}
+	}
+}
+
+// resolve finds the subschema a local reference points to: a JSON Pointer
+// fragment like #/$defs/user or an anchor like #user
+func (v *Validator) resolve(ref string) (interface{}, error) {
+	i := strings.Index(ref, "#")
+	if i == -1 || (i > 0 && ref[:i] != rootID(v.root)) {
+		return nil, fmt.Errorf("remote reference %q is not supported", ref)
+	}
+	fragment := ref[i+1:]
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		if schema := findAnchor(v.root, fragment); schema != nil {
+			return schema, nil
+		}
+		return nil, fmt.Errorf("anchor %q not found", ref)
+	}
+	path, err := query.FromPointer(unescapeFragment(fragment), v.root)
+	if err != nil {
+		return nil, fmt.Errorf("bad reference %q: %s", ref, err)
+	}
+	schema, err := query.Get(v.root, path)
+	if err != nil {
+		return nil, fmt.Errorf("reference %q not found", ref)
+	}
+	return schema, nil
+}
+
+// rootID returns the $id of the schema without the fragment
+func rootID(schema interface{}) string {
+	s, _ := schema.(map[string]interface{})
+	id, _ := s["$id"].(string)
+	return strings.TrimSuffix(id, "#")
+}
+
+// findAnchor looks for the subschema with the "$anchor" or, in draft 7, "$id" naming the fragment
+func findAnchor(schema interface{}, name string) interface{} {
+	switch t := schema.(type) {
+	case map[string]interface{}:
+		if t["$anchor"] == name || t["$id"] == "#"+name {
+			return t
+		}
+		for _, sub := range t {
+			if found := findAnchor(sub, name); found != nil {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, sub := range t {
+			if found := findAnchor(sub, name); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+var percentEscape = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// unescapeFragment decodes the percent escapes URI fragments may contain
+func unescapeFragment(s string) string {
+	return percentEscape.ReplaceAllStringFunc(s, func(esc string) string {
+		b, _ := strconv.ParseUint(esc[1:], 16, 8)
+		return string([]byte{byte(b)})
+	})
+}
+
+func (v *Validator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %s", pattern, err)
+	}
+	v.regexps[pattern] = re
+	return re, nil
+}
+
+// toInt returns the number if it is an integer that fits into int
+func toInt(v interface{}) (int, bool) {
+	r, ok := query.ToRat(v)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
+
+// isInteger reports whether the value is a number without a fractional part, 1.0 included
+func isInteger(v interface{}) bool {
+	r, ok := query.ToRat(v)
+	return ok && r.IsInt()
+}
+
+// encodeValue encodes the value for messages, long values are cut
+func encodeValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	s := string(b)
+	if err != nil {
+		s = fmt.Sprint(v)
+	}
+	if utf8.RuneCountInString(s) > 40 {
+		s = string([]rune(s)[:37]) + "..."
+	}
+	return s
+}
+
+// within reports whether the path is the prefix or a path below it. Wildcards of the prefix match any index
+func within(path, prefix []query.Token) bool {
+	if len(prefix) > 0 && prefix[0] == query.Token(query.Key("")) {
+		prefix = prefix[1:]
+	}
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i, tok := range prefix {
+		if _, ok := path[i].(query.Index); ok && tok == query.ArrayAsterisk {
+			continue
+		}
+		if path[i] != tok {
+			return false
+		}
+	}
+	return true
+}
+
+// SetValidator validates the document and shows the violations next to the values
+func (e *Explorer) SetValidator(v *Validator) {
+	e.validator = v
+	e.violations = v.Validate(e.doc.Interface())
+	e.violation = -1
+	e.noted = nil
+}
+
+// violationNotes returns the failing keywords shown next to the lines of the displayed document.
+// They are found once per displayed node, the contents are drawn after every key press
+func (e *Explorer) violationNotes() map[int]string {
+	if e.noted != e.display.Doc || e.notes == nil {
+		e.noted = e.display.Doc
+		e.notes = e.findViolationNotes()
+	}
+	return e.notes
+}
+
+// findViolationNotes maps the violations to the lines of the displayed document. Strings
+// shown decoded are not the values that were validated, their violations are not noted
+func (e *Explorer) findViolationNotes() map[int]string {
+	notes := map[int]string{}
+	if len(e.violations) == 0 || e.display.Doc == nil || constructed(e.docPath) {
+		return notes
+	}
+	prefix, err := query.ToPointer(e.docPath)
+	if err != nil {
+		return notes
+	}
+	byPointer := map[string][]string{}
+	for _, v := range e.violations {
+		ptr, _ := query.ToPointer(v.Path)
+		if e.decode && e.decoded().within(ptr) {
+			continue
+		}
+		byPointer[ptr] = append(byPointer[ptr], v.Keyword+": "+e.violationMsg(v))
+	}
+	seen := map[string]bool{}
+	for i, path := range linePaths(e.display.Doc.Interface()) {
+		ptr, _ := query.ToPointer(path)
+		// closing brackets point to their container, the note goes to the opening line only
+		if seen[ptr] {
+			continue
+		}
+		seen[ptr] = true
+		if msgs, ok := byPointer[prefix+ptr]; ok {
+			notes[i] = strings.Join(msgs, "; ")
+		}
+	}
+	return notes
+}
+
+func (e *Explorer) nextViolation() {
+	e.jumpToViolation(1)
+}
+
+func (e *Explorer) prevViolation() {
+	e.jumpToViolation(-1)
+}
+
+// jumpToViolation sets the query to the path of the next or the previous violation
+func (e *Explorer) jumpToViolation(step int) {
+	if e.validator == nil {
+		e.message = "no schema, give one with -schema"
+		return
+	}
+	n := len(e.violations)
+	if n == 0 {
+		e.message = "the document matches the schema"
+		return
+	}
+	i := e.violation + step
+	if e.violation == -1 && step < 0 {
+		i = n - 1
+	}
+	e.violation = (i%n + n) % n
+	v := e.violations[e.violation]
+	e.message = fmt.Sprintf("%d/%d %s: %s", e.violation+1, n, v.Keyword, e.violationMsg(v))
+	e.display.ActiveCompletion = -1
+	e.query.SetRaw(e.query.Format(v.Path))
+	e.query.QueryPos = utf8.RuneCountInString(e.query.Raw())
+	e.syncWithQuery()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qoops-1/vuje/query"
+)
+
+func violationStrings(vs []Violation) []string {
+	var strs []string
+	for _, v := range vs {
+		strs = append(strs, v.String())
+	}
+	return strs
+}
+
+func TestValidator(t *testing.T) {
+	tbl := []struct {
+		schema string
+		doc    string
+		res    []string
+	}{
+		{schema: `true`, doc: `1`},
+		{schema: `false`, doc: `1`, res: []string{"(root): false: no value is allowed here"}},
+		{schema: `{"type": "integer"}`, doc: `1.0`},
+		{schema: `{"type": ["string", "null"]}`, doc: `1.5`, res: []string{"(root): type: expected string or null, got number"}},
+		{schema: `{"enum": [1, "a"]}`, doc: `1.0`},
+		{schema: `{"const": {"a": [1]}}`, doc: `{"a": [2]}`, res: []string{`(root): const: expected {"a":[1]}, got {"a":[2]}`}},
+		{
+			schema: `{"minimum": 1, "exclusiveMaximum": 9007199254740993, "multipleOf": 0.1}`,
+			doc:    `[0.5, 9007199254740993, 1.33]`,
+			res:    nil,
+		},
+		{
+			schema: `{"items": {"minimum": 1, "exclusiveMaximum": 9007199254740993, "multipleOf": 0.1}}`,
+			doc:    `[0.5, 9007199254740993, 1.33]`,
+			res: []string{
+				"/0: minimum: 0.5 is less than 1",
+				"/1: exclusiveMaximum: 9007199254740993 is not less than 9007199254740993",
+				"/2: multipleOf: 1.33 is not a multiple of 0.1",
+			},
+		},
+		{
+			schema: `{"minLength": 2, "maxLength": 3, "pattern": "^[a-z]+$"}`,
+			doc:    `"ключи"`,
+			res:    []string{"(root): maxLength: length 5 is greater than 3", `(root): pattern: "ключи" does not match "^[a-z]+$"`},
+		},
+		{
+			schema: `{"prefixItems": [{"type": "string"}], "items": false, "contains": {"type": "null"}, "maxItems": 2, "uniqueItems": true}`,
+			doc:    `[1, 2, 2]`,
+			res: []string{
+				"/0: type: expected string, got number",
+				"/1: false: no value is allowed here",
+				"/2: false: no value is allowed here",
+				"(root): contains: 0 elements match the schema, at least 1 required",
+				"(root): maxItems: 3 elements, at most 2 allowed",
+				"(root): uniqueItems: elements [1] and [2] are equal",
+			},
+		},
+		{
+			schema: `{"$schema": "http://json-schema.org/draft-07/schema#", "items": [{"type": "string"}], "additionalItems": {"type": "number"}}`,
+			doc:    `["a", "b"]`,
+			res:    []string{"/1: type: expected number, got string"},
+		},
+		{
+			schema: `{
+				"required": ["id", "name"],
+				"properties": {"id": {"type": "integer"}},
+				"patternProperties": {"^x-": {"type": "string"}},
+				"additionalProperties": false,
+				"propertyNames": {"maxLength": 3},
+				"dependentRequired": {"id": ["name"]}
+			}`,
+			doc: `{"id": "1", "x-a": 1, "other/key": true}`,
+			res: []string{
+				`(root): required: missing key "name"`,
+				"/id: type: expected integer, got string",
+				`(root): propertyNames: key "other/key" does not match the schema of property names`,
+				`(root): additionalProperties: key "other/key" is not allowed`,
+				"/x-a: type: expected string, got number",
+				`(root): dependentRequired: key "id" requires key "name"`,
+			},
+		},
+		{
+			schema: `{"anyOf": [{"type": "string"}, {"minimum": 5}], "oneOf": [{"type": "number"}, {"type": "integer"}], "not": {"const": 3}}`,
+			doc:    `3`,
+			res: []string{
+				"(root): anyOf: value matches none of the 2 schemas",
+				"(root): oneOf: value matches more than one schema: 0, 1",
+				"(root): not: value must not match the schema",
+			},
+		},
+		{
+			schema: `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`,
+			doc:    `[{"kind": "a"}, {"kind": "b"}]`,
+			res:    nil,
+		},
+		{
+			schema: `{"items": {"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}}`,
+			doc:    `[{"kind": "a"}, {"kind": "b"}]`,
+			res:    []string{`/0: required: missing key "a"`, `/1: required: missing key "b"`},
+		},
+		{
+			schema: `{
+				"$id": "https://example.com/user.json",
+				"$defs": {"user": {"$anchor": "u", "properties": {"friends": {"items": {"$ref": "#/$defs/user"}}, "name": {"type": "string"}}}},
+				"properties": {"a": {"$ref": "#u"}, "b": {"$ref": "https://example.com/user.json#/$defs/user"}, "c": {"$ref": "other.json"}}
+			}`,
+			doc: `{"a": {"friends": [{"name": 1}]}, "b": {"name": null}, "c": 1}`,
+			res: []string{
+				"/a/friends/0/name: type: expected string, got number",
+				"/b/name: type: expected string, got null",
+				`/c: $ref: remote reference "other.json" is not supported`,
+			},
+		},
+		{schema: `{"$ref": "#"}`, doc: `1`, res: []string{`(root): $ref: "#" refers to itself`}},
+	}
+	for _, tt := range tbl {
+		v, err := NewValidator(decode(t, tt.schema))
+		assert.NoError(t, err, tt.schema)
+		assert.Equal(t, tt.res, violationStrings(v.Validate(decode(t, tt.doc))), tt.schema)
+	}
+
+	_, err := NewValidator(decode(t, `[]`))
+	assert.EqualError(t, err, "schema must be an object or a boolean, got array")
+}
+
+func TestValidator_ValidateAppended(t *testing.T) {
+	v, err := NewValidator(decode(t, `{"type": "array", "items": {"required": ["level"]}}`))
+	assert.NoError(t, err)
+	arr := decode(t, `[{}, {"level": 1}, {"msg": "x"}]`).([]interface{})
+	vs, ok := v.ValidateAppended(arr, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []string{`/2: required: missing key "level"`}, violationStrings(vs))
+	assert.Equal(t, violationStrings(v.Validate(arr))[1:], violationStrings(vs))
+
+	// the other elements matter
+	v, err = NewValidator(decode(t, `{"items": {}, "maxItems": 2}`))
+	assert.NoError(t, err)
+	_, ok = v.ValidateAppended(arr, 1)
+	assert.False(t, ok)
+}
+
+func TestWithin(t *testing.T) {
+	path := []query.Token{query.Key("users"), query.Index(1), query.Key("id")}
+	assert.True(t, within(path, nil))
+	assert.True(t, within(path, []query.Token{query.Key(""), query.Key("users"), query.ArrayAsterisk}))
+	assert.True(t, within(path, path))
+	assert.False(t, within(path, []query.Token{query.Key("users"), query.Index(0)}))
+	assert.False(t, within(path[:1], path))
+}
+
+func TestExplorer_violations(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"users": [{"id": 1, "email": null}, {"id": "2"}]}`), '.', theme)
+	assert.NoError(t, err)
+	v, err := NewValidator(decode(t, `{
+		"properties": {"users": {"items": {"required": ["email"], "properties": {"id": {"type": "integer"}, "email": {"type": "string"}}}}}
+	}`))
+	assert.NoError(t, err)
+	e.SetValidator(v)
+	scr := newMemScreen(40, 12, script("users")...)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, []string{
+		`    "email": null,  ← type: expected str`,
+		`    "id": 1`,
+		`  },`,
+		`  {  ← required: missing key "email"`,
+		`    "id": "2"  ← type: expected integer,`,
+	}, scr.Lines()[4:9])
+
+	// F7 before any F8 goes to the last violation
+	scr = newMemScreen(40, 12, script(termbox.KeyF8, termbox.KeyF8, termbox.KeyF7, termbox.KeyF7)...)
+	e.screen = scr
+	e.violation = -1
+	e.Run()
+	assert.Equal(t, "users[1].id", e.query.Raw())
+	assert.Equal(t, 2, e.violation)
+	assert.Equal(t, ` "2"  ← type: expected integer, got stri`, scr.Line(2))
+	assert.Equal(t, " string · 3 B  3/3 type: json  1/1 100%", scr.Line(11))
+	// the notes are found once for the displayed node
+	assert.Equal(t, e.display.Doc, e.noted)
+	assert.Equal(t, map[int]string{0: "type: expected integer, got string"}, e.notes)
+}
+
+func TestExplorer_violationsDecoded(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"body": "{\"id\": 7}", "n": "1"}`), '.', theme)
+	assert.NoError(t, err)
+	v, err := NewValidator(decode(t, `{"properties": {"body": {"maxLength": 3}, "n": {"type": "integer"}}}`))
+	assert.NoError(t, err)
+	e.SetValidator(v)
+	scr := newMemScreen(40, 12, script(termbox.KeyCtrlD)...)
+	e.screen = scr
+	e.Run()
+	// the string is validated, the object decoded from it is shown without the note
+	assert.Equal(t, []string{
+		"{",
+		`  "body": {  ← decoded from a string`,
+		`    "id": 7`,
+		"  },",
+		`  "n": "1"  ← type: expected integer, go`,
+	}, scr.Lines()[2:7])
+}
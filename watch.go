@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/pkg/errors"
+	"github.com/qoops-1/vuje/query"
+)
+
+const (
+	// reloadDelay waits for the writes of the watched file to settle before it is read
+	reloadDelay = 100 * time.Millisecond
+	// highlightTime is how long the values changed by a reload stay highlighted
+	highlightTime = 2 * time.Second
+)
+
+// reload is the new contents of the watched file
+type reload struct {
+	name string
+	data []byte
+	err  error
+}
+
+// Watch reloads the document when the file it was read from changes. Reloads are
+// delivered to the explorer with interrupts of the screen
+func (e *Explorer) Watch() (stop func(), err error) {
+	if e.file == "" {
+		return nil, errors.New("only files can be watched, not stdin")
+	}
+	e.reloads = make(chan reload, 1)
+	// the file is watched under the name it had, saving to another one does not move the watch
+	name := e.file
+	var mu sync.Mutex
+	var timer *time.Timer
+	return watchFile(name, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(reloadDelay, func() {
+			data, err := ioutil.ReadFile(name)
+			// only the latest version matters. A reload sent meanwhile by an overlapping
+			// timer read the file as recently, it is kept rather than blocking on it
+			select {
+			case <-e.reloads:
+			default:
+			}
+			select {
+			case e.reloads <- reload{name: name, data: data, err: err}:
+			default:
+			}
+			e.screen.Interrupt()
+		})
+	})
+}
+
+// interrupted handles the pending reload and the new records of the followed stream and ends the highlighting of changed values when its time is over
+func (e *Explorer) interrupted() {
+	select {
+	case r := <-e.reloads:
+		e.reload(r)
+	default:
+	}
+	if e.follow != nil {
+		e.ingest()
+	}
+	if e.highlight != nil && !time.Now().Before(e.highlightUntil) {
+		e.highlight = nil
+		e.drawContents(true)
+	}
+}
+
+// reload replaces the document with the new version of the file keeping the query and
+// the line at the top of the window. The values that changed are highlighted for a while.
+// Changes made by saving the document and changes of a file it is not saved to anymore are ignored
+func (e *Explorer) reload(r reload) {
+	if r.name != e.file || r.err == nil && bytes.Equal(r.data, e.saved) {
+		return
+	}
+	if r.err != nil {
+		e.message = errors.Wrap(r.err, "cant reload").Error()
+		return
+	}
+	doc, err := simplejson.NewJson(r.data)
+	if err != nil {
+		e.message = "cant reload: " + inputError(r.data, err).Error()
+		return
+	}
+	if e.dirty() {
+		e.message = "the file changed, reload skipped to keep the unsaved changes"
+		return
+	}
+	top := e.topLine()
+	changes := diffValues(e.doc.Interface(), doc.Interface(), e.diffKey)
+	e.setDoc(doc.Interface())
+	e.edits, e.savedEdits = nil, 0
+	e.source, e.format = r.data, nil
+	e.highlight = map[string]bool{}
+	for _, c := range changes {
+		if c.Kind == Added || c.Kind == Changed {
+			ptr, _ := query.ToPointer(c.Path)
+			e.highlight[ptr] = true
+		}
+	}
+	e.highlightUntil = time.Now().Add(highlightTime)
+	if len(changes) > 0 {
+		time.AfterFunc(highlightTime, e.screen.Interrupt)
+	}
+	e.message = "reloaded, " + diffSummary(changes)
+	e.syncWithQuery()
+	if top != nil {
+		for i, ptr := range e.linePointers() {
+			if ptr == *top {
+				e.display.DocOffsetY = i
+				e.drawContents(true)
+				break
+			}
+		}
+	}
+}
+
+// linePointers returns the JSON Pointers of the elements starting at the lines of the displayed
+// document, or nil if the lines are not nodes of the document
+func (e *Explorer) linePointers() []string {
+	if e.display.Doc == nil || e.display.Shape || e.display.OnlyKeys || e.other != nil || constructed(e.docPath) {
+		return nil
+	}
+	prefix, err := query.ToPointer(e.docPath)
+	if err != nil {
+		return nil
+	}
+	var ptrs []string
+	for _, path := range linePaths(e.display.Doc.Interface()) {
+		ptr, _ := query.ToPointer(path)
+		ptrs = append(ptrs, prefix+ptr)
+	}
+	return ptrs
+}
+
+// topLine returns the pointer of the element at the top of the window
+func (e *Explorer) topLine() *string {
+	ptrs := e.linePointers()
+	if e.display.DocOffsetY >= len(ptrs) {
+		return nil
+	}
+	return &ptrs[e.display.DocOffsetY]
+}
+
+// highlighted reports which lines of the displayed document show values changed by the last reload
+func (e *Explorer) highlighted() map[int]bool {
+	if len(e.highlight) == 0 {
+		return nil
+	}
+	lines := map[int]bool{}
+	for i, ptr := range e.linePointers() {
+		for p := ptr; ; p = p[:strings.LastIndex(p, "/")] {
+			if e.highlight[p] {
+				lines[i] = true
+				break
+			}
+			if p == "" {
+				break
+			}
+		}
+	}
+	return lines
+}
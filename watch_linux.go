@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// watchFile calls changed after the file is written, created or replaced. The directory
+// is watched with inotify rather than the file, so files replaced by renaming are followed.
+// Closing the inotify descriptor does not wake a blocked read, so the goroutine waits in
+// poll for the events or for stop, which writes to a pipe
+func watchFile(name string, changed func()) (stop func(), err error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cant watch %s", name)
+	}
+	mask := uint32(unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_CREATE | unix.IN_MOVED_TO)
+	if _, err := unix.InotifyAddWatch(fd, filepath.Dir(name), mask); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "cant watch %s", name)
+	}
+	wake := make([]int, 2)
+	if err := unix.Pipe2(wake, unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrapf(err, "cant watch %s", name)
+	}
+	base := filepath.Base(name)
+	go func() {
+		defer unix.Close(wake[0])
+		defer unix.Close(fd)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}, {Fd: int32(wake[0]), Events: unix.POLLIN}}
+		for {
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			if fds[1].Revents != 0 {
+				return
+			}
+			n, err := unix.Read(fd, buf)
+			if err == unix.EINTR || err == unix.EAGAIN {
+				continue
+			}
+			if err != nil || n <= 0 {
+				return
+			}
+			for off := 0; off+unix.SizeofInotifyEvent <= n; {
+				ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				start := off + unix.SizeofInotifyEvent
+				off = start + int(ev.Len)
+				if strings.TrimRight(string(buf[start:off]), "\x00") == base {
+					changed()
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unix.Write(wake[1], []byte{0})
+			unix.Close(wake[1])
+		})
+	}, nil
+}
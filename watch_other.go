@@ -0,0 +1,41 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// pollInterval is how often the modification time of the watched file is checked
+const pollInterval = 500 * time.Millisecond
+
+// watchFile calls changed after the modification time or the size of the file change
+func watchFile(name string, changed func()) (stop func(), err error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			now, err := os.Stat(name)
+			if err != nil {
+				continue
+			}
+			if !now.ModTime().Equal(info.ModTime()) || now.Size() != info.Size() {
+				info = now
+				changed()
+			}
+		}
+	}()
+	return func() { close(done) }, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorer_reload(t *testing.T) {
+	theme, err := LookupTheme("dark", ColorMode8)
+	assert.NoError(t, err)
+	e, err := NewExplorer(strings.NewReader(`{"a": 1, "b": {"c": 2, "d": 3}, "e": [1]}`), '.', theme)
+	assert.NoError(t, err)
+	e.reloads = make(chan reload, 1)
+	e.reloads <- reload{data: []byte(`{"0": 0, "a": 1, "b": {"c": 5, "d": 3}, "e": [1]}`)}
+	interrupt := termbox.Event{Type: termbox.EventInterrupt}
+	scr := newMemScreen(40, 12, script("b", interrupt)...)
+	e.screen = scr
+	e.Run()
+	assert.Equal(t, "reloaded, 1 added, 1 changed", e.message)
+	assert.Equal(t, "b", e.query.Raw())
+	assert.Equal(t, []string{"{", `  "c": 5,`, `  "d": 3`}, scr.Lines()[2:5])
+	assert.Equal(t, termbox.AttrReverse, scr.Cell(2, 3).Fg&termbox.AttrReverse)
+	assert.Equal(t, termbox.Attribute(0), scr.Cell(2, 4).Fg&termbox.AttrReverse)
+
+	// the top line stays when lines are added above it, the highlighting ends with an interrupt after its time
+	long := `[` + strings.Repeat(`1, `, 20) + `1]`
+	e.setDoc(decode(t, `{"a": `+long+`}`))
+	e.reloads <- reload{data: []byte(`{"0": 0, "a": ` + long + `}`)}
+	e.stop = nil
+	e.screen = newMemScreen(40, 12, script(termbox.KeyCtrlN, termbox.KeyCtrlN, termbox.KeyCtrlN, interrupt)...)
+	e.Run()
+	assert.Equal(t, 4, e.display.DocOffsetY)
+	assert.Equal(t, map[string]bool{"/0": true}, e.highlight)
+	e.highlightUntil = time.Now()
+	e.screen = newMemScreen(40, 12, interrupt)
+	e.Run()
+	assert.Nil(t, e.highlight)
+
+	// unsaved changes are not replaced, broken versions are reported
+	e.reloads <- reload{data: []byte(`{"a": 2}`)}
+	e.screen = newMemScreen(40, 12, script("a", termbox.KeyDelete, interrupt)...)
+	e.Run()
+	assert.Equal(t, "the file changed, reload skipped to keep the unsaved changes", e.message)
+	e.edits = nil
+	e.reloads <- reload{data: []byte(`{"a": `)}
+	e.screen = newMemScreen(40, 12, interrupt)
+	e.Run()
+	assert.Equal(t, "cant reload: cant parse json: unexpected EOF", e.message)
+
+	// saving keeps the undo history, the reload of our own write is ignored
+	dir, err := ioutil.TempDir("", "vuje")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "doc.json")
+	e.file = name
+	e.setDoc(decode(t, `{"a": 1, "b": 2}`))
+	e.screen = newMemScreen(40, 12, script(termbox.KeyCtrlU, "a", termbox.KeyDelete, termbox.KeyCtrlS)...)
+	e.Run()
+	data, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	e.reloads <- reload{name: name, data: data}
+	e.screen = newMemScreen(40, 12, interrupt)
+	e.Run()
+	assert.Equal(t, "saved 7 B to "+name, e.message)
+	assert.Len(t, e.edits, 1)
+	// the file the document was saved to before is not watched anymore
+	e.file = filepath.Join(dir, "other.json")
+	e.reloads <- reload{name: name, data: []byte(`{"b": 1}`)}
+	e.screen = newMemScreen(40, 12, interrupt)
+	e.Run()
+	assert.Equal(t, `{"b":2}`, compactJSON(e.doc.Interface()))
+}
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vuje")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "state.json")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(`1`), 0644))
+	changed := make(chan struct{}, 100)
+	stop, err := watchFile(name, func() { changed <- struct{}{} })
+	assert.NoError(t, err)
+	defer stop()
+
+	wait := func(what string) {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change reported after " + what)
+		}
+		time.Sleep(50 * time.Millisecond)
+		for len(changed) > 0 {
+			<-changed
+		}
+	}
+	// polling watchers see changes of the modification time only
+	const settle = 600 * time.Millisecond
+	time.Sleep(settle)
+	assert.NoError(t, ioutil.WriteFile(name, []byte(`22`), 0644))
+	wait("writing")
+	assert.NoError(t, writeFileAtomic(name, []byte(`333`)))
+	wait("replacing")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte(`1`), 0644))
+	select {
+	case <-changed:
+		t.Fatal("change of another file reported")
+	case <-time.After(settle):
+	}
+}
+
+func TestWatchFile_stop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vuje")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "state.json")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(`1`), 0644))
+	before := runtime.NumGoroutine()
+	changed := make(chan struct{}, 100)
+	stop, err := watchFile(name, func() { changed <- struct{}{} })
+	assert.NoError(t, err)
+
+	// the watching goroutine ends even while it waits for events
+	stop()
+	stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= before, "the watching goroutine is still running")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(`22`), 0644))
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, changed, 0)
+}